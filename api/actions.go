@@ -39,7 +39,7 @@ func (c *Client) ListActions(entityType string) ([]Action, error) {
 		}
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -120,7 +120,7 @@ func (c *Client) GetAction(entityType, actionName string) (*Action, error) {
 		}
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -159,6 +159,9 @@ func (c *Client) GetAction(entityType, actionName string) (*Action, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse action schema: %w", err)
 	}
+	if action.OnType == "" {
+		action.OnType = entityType
+	}
 
 	return &action, nil
 }
@@ -193,7 +196,7 @@ func (c *Client) ExecuteAction(entityType, actionName string, inputs map[string]
 		}
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
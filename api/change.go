@@ -0,0 +1,37 @@
+// api/change.go
+
+package api
+
+// ChangeKind is the kind of change made to a resource.
+type ChangeKind string
+
+const (
+	ChangeUpdate     ChangeKind = "update"
+	ChangeDelete     ChangeKind = "delete"
+	ChangeRelation   ChangeKind = "relation"
+	ChangePermission ChangeKind = "permission"
+)
+
+// Change describes a change about to be made to a resource.
+type Change struct {
+	Kind       ChangeKind
+	Type       string
+	ID         string
+	Attributes []string // Attributes an update writes
+	Relations  []string // Relationships an update or relation change writes
+}
+
+// Recorder is told about every change to a resource before it is made, so
+// that it can keep what is needed to undo it. The returned function is
+// called with the outcome of the change.
+type Recorder interface {
+	Before(change Change) (after func(err error))
+}
+
+// record tells the Recorder, if any, about a change.
+func (c *Client) record(change Change) func(error) {
+	if c.Recorder == nil {
+		return func(error) {}
+	}
+	return c.Recorder.Before(change)
+}
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -19,8 +20,25 @@ type Client struct {
 	HTTPClient *http.Client
 	Headers    http.Header
 	APIKey     string // Add this line
+
+	// Retries is the number of times a read that failed without an answer,
+	// or with a status saying the server is busy or unavailable, is tried
+	// again.
+	Retries int
+
+	// DryRun, when set, sees every request that would change data, with its
+	// body, just before it is sent. Returning true stops the request, which
+	// then fails with ErrDryRun. Reads are always sent.
+	DryRun func(req *http.Request, body []byte) bool
+
+	// Recorder, when set, is told about updates, deletes, relationship and
+	// permission changes before they are made.
+	Recorder Recorder
 }
 
+// ErrDryRun is returned for requests stopped by Client.DryRun.
+var ErrDryRun = errors.New("dry run: request not sent")
+
 // NewClient creates a new API client with the specified base URL and API key.
 func NewClient(baseURL string, apiKey string) (*Client, error) { // Modify this line
 	parsedURL, err := url.Parse(baseURL)
@@ -35,6 +53,7 @@ func NewClient(baseURL string, apiKey string) (*Client, error) { // Modify this
 		},
 		Headers: make(http.Header),
 		APIKey:  apiKey, // Add this line
+		Retries: 2,
 	}
 
 	// Set default headers
@@ -58,8 +77,7 @@ func (c *Client) doRequest(req *http.Request, v interface{}) error {
 		}
 	}
 
-	utils.InfoLogger.Printf("Request URL: %s", req.URL)
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -73,12 +91,68 @@ func (c *Client) doRequest(req *http.Request, v interface{}) error {
 	}
 
 	// Handle error responses
-	var apiError APIError
-	if err := json.NewDecoder(resp.Body).Decode(&apiError); err != nil {
-		return fmt.Errorf("failed to decode error response: %w", err)
+	apiError := &APIError{StatusCode: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(apiError); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: failed to decode error response: %w", resp.Status, err)
+	}
+
+	return apiError
+}
+
+// send sends a request, unless DryRun stops it, and logs it for -debug.
+// Reads are tried again, after a growing pause, while the server cannot be
+// reached or answers that it is busy or unavailable.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	read := req.Method == http.MethodGet || req.Method == http.MethodHead
+	if c.DryRun != nil && !read {
+		var body []byte
+		if req.GetBody != nil {
+			if reader, err := req.GetBody(); err == nil {
+				body, _ = io.ReadAll(reader)
+				reader.Close()
+			}
+		}
+		if c.DryRun(req, body) {
+			return nil, ErrDryRun
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, err := c.HTTPClient.Do(req)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			utils.DebugLogger.Printf("%s %s: %v after %s", req.Method, req.URL, err, elapsed)
+		} else {
+			utils.DebugLogger.Printf("%s %s: %s in %s", req.Method, req.URL, resp.Status, elapsed)
+		}
+		if !read || attempt >= c.Retries || !retryable(resp, err) {
+			return resp, err
+		}
+
+		pause := time.Duration(500<<attempt) * time.Millisecond
+		if resp != nil {
+			if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds >= 0 {
+				pause = time.Duration(seconds) * time.Second
+			}
+			resp.Body.Close()
+		}
+		utils.DebugLogger.Printf("Trying %s %s again in %s", req.Method, req.URL, pause)
+		time.Sleep(pause)
 	}
+}
 
-	return errors.New(apiError.Error())
+// retryable reports whether a request may succeed when sent again: it got
+// no answer, or the server was busy or unavailable.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
 }
 
 func (c *Client) GetResource(path string) (map[string]interface{}, error) {
@@ -96,7 +170,7 @@ func (c *Client) GetResource(path string) (map[string]interface{}, error) {
 		}
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, err
 	}
@@ -142,7 +216,7 @@ func (c *Client) PatchResource(path string, data map[string]interface{}) (map[st
 		}
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, err
 	}
@@ -168,7 +242,8 @@ func (c *Client) PatchResource(path string, data map[string]interface{}) (map[st
 
 // APIError represents an error returned by the API.
 type APIError struct {
-	Errors []struct {
+	StatusCode int `json:"-"`
+	Errors     []struct {
 		Status string `json:"status"`
 		Title  string `json:"title"`
 		Detail string `json:"detail"`
@@ -180,11 +255,29 @@ func (e *APIError) Error() string {
 	if len(e.Errors) > 0 {
 		return fmt.Sprintf("%s: %s", e.Errors[0].Title, e.Errors[0].Detail)
 	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("request failed: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
 	return "unknown API error"
 }
 
+// IsNotFound reports whether err is an API error for a missing resource.
+func IsNotFound(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound
+}
+
 // get sends a GET request.
 func (c *Client) get(path string, queryParams map[string]string, v interface{}) error {
+	values := make(url.Values)
+	for key, value := range queryParams {
+		values.Set(key, value)
+	}
+	return c.getValues(path, values, v)
+}
+
+// getValues sends a GET request with query parameters that may repeat.
+func (c *Client) getValues(path string, values url.Values, v interface{}) error {
 	rel, err := url.Parse(path)
 	if err != nil {
 		return fmt.Errorf("invalid path: %w", err)
@@ -194,8 +287,10 @@ func (c *Client) get(path string, queryParams map[string]string, v interface{})
 
 	// Add query parameters
 	q := u.Query()
-	for key, value := range queryParams {
-		q.Set(key, value)
+	for key, list := range values {
+		for _, value := range list {
+			q.Add(key, value)
+		}
 	}
 	u.RawQuery = q.Encode()
 
@@ -0,0 +1,14 @@
+// api/client_test.go
+
+package api
+
+import (
+	"dcli/utils"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger(false)
+	os.Exit(m.Run())
+}
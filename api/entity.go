@@ -3,12 +3,15 @@
 package api
 
 import (
-	"dcli/utils"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // ForeignKeyData represents foreign key information for a column.
@@ -84,6 +87,61 @@ type TableInfo struct {
 	Actions                []Action              `json:"Actions"`
 	StateMachines          []interface{}         `json:"StateMachines"`
 	IsStateMachineEnabled  bool                  `json:"IsStateMachineEnabled"`
+
+	// ColumnOrder lists the keys of ColumnModel in the order the server sent them.
+	ColumnOrder []string `json:"-"`
+}
+
+// UnmarshalJSON decodes a table model, recording the order of its columns.
+func (t *TableInfo) UnmarshalJSON(data []byte) error {
+	type tableInfo TableInfo
+	var decoded tableInfo
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	var raw struct {
+		ColumnModel json.RawMessage `json:"ColumnModel"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	order, err := objectKeys(raw.ColumnModel)
+	if err != nil {
+		return err
+	}
+
+	*t = TableInfo(decoded)
+	t.ColumnOrder = order
+	return nil
+}
+
+// objectKeys returns the keys of a JSON object in document order.
+func objectKeys(data json.RawMessage) ([]string, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v in object", token)
+		}
+		keys = append(keys, key)
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
 }
 
 type ColumnInfo struct {
@@ -144,10 +202,7 @@ func (c *Client) GetEntityModel(entityName string) (*TableInfo, error) {
 		}
 	}
 
-	// Optional: Log the request URL
-	utils.InfoLogger.Printf("Request URL: %s", req.URL)
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -170,3 +225,82 @@ func (c *Client) GetEntityModel(entityName string) (*TableInfo, error) {
 
 	return &model, nil
 }
+
+// textColumnTypes are the daptin column types holding free text worth searching.
+var textColumnTypes = map[string]struct{}{
+	"label":    {},
+	"name":     {},
+	"email":    {},
+	"content":  {},
+	"markdown": {},
+	"html":     {},
+	"url":      {},
+	"alias":    {},
+}
+
+// SearchableColumns returns the sorted names of the text-like columns of the
+// entity, leaving out relations, foreign keys and columns hidden from the API.
+func (t *TableInfo) SearchableColumns() []string {
+	var columns []string
+	for name, col := range t.ColumnModel {
+		if col.JsonApi != "" || col.IsForeignKey || col.ExcludeFromApi || name == "reference_id" {
+			continue
+		}
+		_, textType := textColumnTypes[col.ColumnType]
+		textData := col.ColumnType == "" &&
+			(strings.HasPrefix(col.DataType, "varchar") || strings.HasPrefix(col.DataType, "text"))
+		if textType || textData {
+			columns = append(columns, name)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// ListEntities returns the sorted names of the entities exposed by the server,
+// as recorded in daptin's world table. Hidden and join tables are skipped.
+func (c *Client) ListEntities() ([]string, error) {
+	doc, err := c.List("world", &ListOptions{Page: map[string]string{"size": "1000"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+	tables, err := parseResourceList(doc.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse entities: %w", err)
+	}
+
+	var names []string
+	for _, table := range tables {
+		if Truthy(table.Attributes["is_hidden"]) || Truthy(table.Attributes["is_join_table"]) {
+			continue
+		}
+		if name, ok := table.Attributes["table_name"].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Truthy interprets the boolean encodings daptin uses in attributes:
+// booleans, numbers other than 0, and strings such as "true", "t" or "1" in
+// any case.
+func Truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case float64:
+		return b != 0
+	case int64:
+		return b != 0
+	case int:
+		return b != 0
+	case json.Number:
+		f, err := b.Float64()
+		return err == nil && f != 0
+	case string:
+		truth, _ := strconv.ParseBool(strings.ToLower(strings.TrimSpace(b)))
+		return truth
+	}
+	return false
+}
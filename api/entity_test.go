@@ -0,0 +1,44 @@
+// api/entity_test.go
+
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTruthy(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{true, true},
+		{false, false},
+		{float64(1), true},
+		{float64(0), false},
+		{int64(2), true},
+		{int64(0), false},
+		{1, true},
+		{0, false},
+		{json.Number("1"), true},
+		{json.Number("0"), false},
+		{json.Number("x"), false},
+		{"true", true},
+		{"TRUE", true},
+		{"tRuE", true},
+		{"t", true},
+		{"1", true},
+		{" true ", true},
+		{"false", false},
+		{"0", false},
+		{"yes", false},
+		{"", false},
+		{nil, false},
+		{[]interface{}{true}, false},
+	}
+	for _, tt := range tests {
+		if got := Truthy(tt.value); got != tt.want {
+			t.Errorf("Truthy(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -4,9 +4,108 @@ package api
 
 import (
 	"dcli/models"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"strings"
 )
 
+// Condition is a single entry of daptin's `query` parameter.
+type Condition struct {
+	Column   string      `json:"column"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// whereOperators maps the comparison symbols accepted by ParseWhere to
+// daptin query operators. Longer symbols come first so that ">=" wins over ">".
+var whereOperators = []struct {
+	Symbol   string
+	Operator string
+}{
+	{"!=", "neq"},
+	{">=", "gte"},
+	{"<=", "lte"},
+	{"~", "like"},
+	{"=", "eq"},
+	{">", "gt"},
+	{"<", "lt"},
+}
+
+// ParseWhere parses a comma-separated list of conditions such as
+// "name=england,age>=3,title~draft" into query conditions. A value may be
+// quoted with single or double quotes to keep commas and surrounding spaces,
+// e.g. title="Hello, world".
+func ParseWhere(expr string) ([]Condition, error) {
+	var conditions []Condition
+	for rest := strings.TrimSpace(expr); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == ',' {
+			rest = rest[1:]
+			continue
+		}
+		condition, next, err := parseCondition(rest)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		rest = next
+	}
+	return conditions, nil
+}
+
+// parseCondition parses the condition at the start of expr and returns the
+// text after the comma that ends it.
+func parseCondition(expr string) (Condition, string, error) {
+	end := strings.IndexByte(expr, ',')
+	if end < 0 {
+		end = len(expr)
+	}
+	index, symbol, operator := -1, "", ""
+	for _, op := range whereOperators {
+		i := strings.Index(expr[:end], op.Symbol)
+		if i < 0 {
+			continue
+		}
+		if index < 0 || i < index || (i == index && len(op.Symbol) > len(symbol)) {
+			index, symbol, operator = i, op.Symbol, op.Operator
+		}
+	}
+	if index < 0 || strings.TrimSpace(expr[:index]) == "" {
+		return Condition{}, "", fmt.Errorf("invalid where condition %q, expected <column><op><value>", strings.TrimSpace(expr[:end]))
+	}
+	condition := Condition{Column: strings.TrimSpace(expr[:index]), Operator: operator}
+
+	value := strings.TrimLeft(expr[index+len(symbol):], " \t")
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		closing := strings.IndexByte(value[1:], value[0]) + 1
+		if closing == 0 {
+			return Condition{}, "", fmt.Errorf("invalid where condition %q, missing closing %c", strings.TrimSpace(expr), value[0])
+		}
+		condition.Value = value[1:closing]
+		rest := strings.TrimLeft(value[closing+1:], " \t")
+		if rest != "" && rest[0] != ',' {
+			return Condition{}, "", fmt.Errorf("invalid where condition %q, expected a comma after the quoted value", strings.TrimSpace(expr))
+		}
+		return condition, strings.TrimPrefix(rest, ","), nil
+	}
+
+	rest := ""
+	if comma := strings.IndexByte(value, ','); comma >= 0 {
+		value, rest = value[:comma], value[comma+1:]
+	}
+	condition.Value = strings.TrimSpace(value)
+	return condition, rest, nil
+}
+
+// encodeQuery encodes conditions as the base64 JSON array expected by daptin.
+func encodeQuery(conditions []Condition) (string, error) {
+	data, err := json.Marshal(conditions)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode query: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
 func (c *Client) Filter(resourceType string, filters map[string]string) (*models.Document, error) {
 	path := fmt.Sprintf("api/%s", resourceType)
 	queryParams := make(map[string]string)
@@ -0,0 +1,143 @@
+// api/filtering_test.go
+
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWhere(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []Condition
+	}{
+		{"", nil},
+		{" , ,", nil},
+		{"name=england", []Condition{{"name", "eq", "england"}}},
+		{"name!=england", []Condition{{"name", "neq", "england"}}},
+		{"age>3", []Condition{{"age", "gt", "3"}}},
+		{"age>=3", []Condition{{"age", "gte", "3"}}},
+		{"age<3", []Condition{{"age", "lt", "3"}}},
+		{"age<=3", []Condition{{"age", "lte", "3"}}},
+		{"title~draft", []Condition{{"title", "like", "draft"}}},
+		{
+			"name=england, age>=3 ,title~draft",
+			[]Condition{{"name", "eq", "england"}, {"age", "gte", "3"}, {"title", "like", "draft"}},
+		},
+		{" name = england ", []Condition{{"name", "eq", "england"}}},
+		{"name=", []Condition{{"name", "eq", ""}}},
+		{"name=,age>3", []Condition{{"name", "eq", ""}, {"age", "gt", "3"}}},
+
+		// The first operator ends the column; the rest belongs to the value
+		{"url=a=b", []Condition{{"url", "eq", "a=b"}}},
+		{"expr!=a=b", []Condition{{"expr", "neq", "a=b"}}},
+		{"range>=1<2", []Condition{{"range", "gte", "1<2"}}},
+		{"name=O'Brien", []Condition{{"name", "eq", "O'Brien"}}},
+
+		{`title="Hello, world"`, []Condition{{"title", "eq", "Hello, world"}}},
+		{`title='Hello, world'`, []Condition{{"title", "eq", "Hello, world"}}},
+		{`title=" padded "`, []Condition{{"title", "eq", " padded "}}},
+		{`title="a=b,c>d"`, []Condition{{"title", "eq", "a=b,c>d"}}},
+		{`title="it's"`, []Condition{{"title", "eq", "it's"}}},
+		{`title=''`, []Condition{{"title", "eq", ""}}},
+		{
+			`title~"x, y" , age<3,name='a,b'`,
+			[]Condition{{"title", "like", "x, y"}, {"age", "lt", "3"}, {"name", "eq", "a,b"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseWhere(tt.expr)
+		if err != nil {
+			t.Errorf("ParseWhere(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseWhere(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseWhereErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"name", `invalid where condition "name"`},
+		{"=england", `invalid where condition "=england"`},
+		{" >=3", `invalid where condition ">=3"`},
+		{"name=a,age", `invalid where condition "age"`},
+		{"a,b=c", `invalid where condition "a"`},
+		{`title="unterminated`, "missing closing \""},
+		{`title='unterminated,age>3`, "missing closing '"},
+		{`title="quoted"trailing`, "expected a comma after the quoted value"},
+	}
+	for _, tt := range tests {
+		got, err := ParseWhere(tt.expr)
+		if err == nil {
+			t.Errorf("ParseWhere(%q) = %v, want an error", tt.expr, got)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseWhere(%q) failed with %q, want it to mention %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestEncodeQuery(t *testing.T) {
+	tests := []struct {
+		conditions []Condition
+		want       string
+	}{
+		{nil, `null`},
+		{[]Condition{}, `[]`},
+		{
+			[]Condition{{"name", "eq", "england"}},
+			`[{"column":"name","operator":"eq","value":"england"}]`,
+		},
+		{
+			[]Condition{{"title", "like", "a,b=\"c\""}, {"age", "gte", 3}},
+			`[{"column":"title","operator":"like","value":"a,b=\"c\""},{"column":"age","operator":"gte","value":3}]`,
+		},
+	}
+	for _, tt := range tests {
+		encoded, err := encodeQuery(tt.conditions)
+		if err != nil {
+			t.Errorf("encodeQuery(%v) failed: %v", tt.conditions, err)
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Errorf("encodeQuery(%v) = %q, not standard base64: %v", tt.conditions, encoded, err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("encodeQuery(%v) decodes to %s, want %s", tt.conditions, data, tt.want)
+		}
+	}
+
+	// A parsed expression survives the round trip through the query parameter
+	conditions, err := ParseWhere(`title="a, b",age>=3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := encodeQuery(conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(encoded)
+	var decoded []Condition
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, conditions) {
+		t.Errorf("round trip = %v, want %v", decoded, conditions)
+	}
+
+	if _, err := encodeQuery([]Condition{{"a", "eq", make(chan int)}}); err == nil {
+		t.Error("encodeQuery of an unencodable value succeeded, want an error")
+	}
+}
@@ -4,12 +4,16 @@ package api
 
 import (
 	"dcli/models"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 )
 
 type ListOptions struct {
 	Page    map[string]string
 	Filter  map[string]string
+	Query   []Condition
 	Sort    string
 	Include string
 	Fields  map[string]string
@@ -26,11 +30,18 @@ func (c *Client) List(resourceType string, options *ListOptions) (*models.Docume
 		for k, v := range options.Filter {
 			queryParams[fmt.Sprintf("filter[%s]", k)] = v
 		}
+		if len(options.Query) > 0 {
+			query, err := encodeQuery(options.Query)
+			if err != nil {
+				return nil, err
+			}
+			queryParams["query"] = query
+		}
 		if options.Sort != "" {
 			queryParams["sort"] = options.Sort
 		}
 		if options.Include != "" {
-			queryParams["include"] = options.Include
+			queryParams["included_relations"] = options.Include
 		}
 		for k, v := range options.Fields {
 			queryParams[fmt.Sprintf("fields[%s]", k)] = v
@@ -44,3 +55,167 @@ func (c *Client) List(resourceType string, options *ListOptions) (*models.Docume
 	}
 	return &respDoc, nil
 }
+
+// Pagination describes where a page of a list response sits in the full result set.
+type Pagination struct {
+	Total    int  // Number of resources matching the query
+	HasTotal bool // Whether the server reported Total
+	Page     int
+	LastPage int
+	PerPage  int
+	Offset   int // Zero-based position of the first resource of the page
+	Links    *models.Links
+}
+
+// PaginationOf extracts pagination details from the links and meta of a list response.
+func PaginationOf(doc *models.Document) Pagination {
+	var p Pagination
+	if doc == nil {
+		return p
+	}
+
+	if links := doc.Links; links != nil {
+		p.Links = links
+		p.Page = links.CurrentPage
+		p.LastPage = links.LastPage
+		p.PerPage = links.PerPage
+		p.Offset = links.From
+		if links.Total > 0 || links.PerPage > 0 {
+			p.Total, p.HasTotal = links.Total, true
+		}
+	}
+
+	if !p.HasTotal {
+		for _, key := range []string{"total_count", "total", "count"} {
+			if total, ok := intValue(doc.Meta[key]); ok {
+				p.Total, p.HasTotal = total, true
+				break
+			}
+		}
+	}
+
+	if p.Offset == 0 && p.Page > 1 && p.PerPage > 0 {
+		p.Offset = (p.Page - 1) * p.PerPage
+	}
+	if p.LastPage == 0 && p.HasTotal && p.PerPage > 0 {
+		p.LastPage = (p.Total + p.PerPage - 1) / p.PerPage
+	}
+	return p
+}
+
+// Count returns the number of resources matching the options. The total is
+// read from the metadata of a single-item list request, falling back to the
+// aggregate endpoint when the server does not report one.
+func (c *Client) Count(resourceType string, options *ListOptions) (int, error) {
+	probe := ListOptions{}
+	if options != nil {
+		probe = *options
+	}
+	probe.Page = map[string]string{"number": "1", "size": "1"}
+
+	doc, err := c.List(resourceType, &probe)
+	if err != nil {
+		return 0, err
+	}
+	if p := PaginationOf(doc); p.HasTotal {
+		return p.Total, nil
+	}
+
+	return c.aggregateCount(resourceType, options)
+}
+
+// ListAll pages through every resource matching the options and passes each
+// page to fn, stopping at the first error fn returns. The page size in the
+// options is kept, 100 by default, and the page number advances from the one
+// in the options until the last page.
+func (c *Client) ListAll(resourceType string, options *ListOptions, fn func(page []*models.Resource) error) error {
+	opts := ListOptions{}
+	if options != nil {
+		opts = *options
+	}
+	page := map[string]string{"size": "100", "number": "1"}
+	for k, v := range opts.Page {
+		page[k] = v
+	}
+	opts.Page = page
+	size, _ := strconv.Atoi(page["size"])
+	number, err := strconv.Atoi(page["number"])
+	if err != nil {
+		return fmt.Errorf("invalid page number %q", page["number"])
+	}
+
+	for ; ; number++ {
+		page["number"] = strconv.Itoa(number)
+		doc, err := c.List(resourceType, &opts)
+		if err != nil {
+			return err
+		}
+		resources, err := doc.Resolve()
+		if err != nil {
+			return err
+		}
+		if len(resources) > 0 {
+			if err := fn(resources); err != nil {
+				return err
+			}
+		}
+
+		p := PaginationOf(doc)
+		if len(resources) == 0 || (p.LastPage > 0 && number >= p.LastPage) ||
+			(p.LastPage == 0 && len(resources) < size) {
+			return nil
+		}
+	}
+}
+
+// aggregateCount counts resources through daptin's aggregate endpoint.
+func (c *Client) aggregateCount(resourceType string, options *ListOptions) (int, error) {
+	path := fmt.Sprintf("aggregate/%s", resourceType)
+	values := url.Values{"column": {"count"}}
+	if options != nil {
+		for k, v := range options.Filter {
+			values.Add("filter", fmt.Sprintf("eq(%s,%s)", k, v))
+		}
+		for _, condition := range options.Query {
+			values.Add("filter", fmt.Sprintf("%s(%s,%v)", condition.Operator, condition.Column, condition.Value))
+		}
+	}
+
+	var respDoc models.Document
+	err := c.getValues(path, values, &respDoc)
+	if err != nil {
+		return 0, fmt.Errorf("failed to aggregate count: %w", err)
+	}
+
+	rows, err := parseResourceList(respDoc.Data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse aggregate response: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	count, ok := intValue(rows[0].Attributes["count"])
+	if !ok {
+		return 0, fmt.Errorf("aggregate response has no count")
+	}
+	return count, nil
+}
+
+// intValue converts a decoded JSON number or numeric string to an int.
+func intValue(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
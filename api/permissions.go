@@ -82,26 +82,26 @@ func AuthPermissionToStrings(perm AuthPermission) []string {
 }
 
 func (c *Client) GetPermissions(entityType, objectID string) (AuthPermission, error) {
-	path := fmt.Sprintf("%s/%s", entityType, objectID)
-	respData, err := c.GetResource(path)
-	if err != nil {
-		return 0, err
-	}
+	perm, _, err := c.GetVersionedPermissions(entityType, objectID)
+	return perm, err
+}
 
-	// Assuming the permission field is named "permission" in the JSON response
-	data, ok := respData["data"].(map[string]interface{})
-	if !ok {
-		return 0, fmt.Errorf("invalid response format")
+// GetVersionedPermissions returns the permission of a resource together with
+// the version it was read at, or -1 when the resource has no version.
+func (c *Client) GetVersionedPermissions(entityType, objectID string) (AuthPermission, int64, error) {
+	res, err := c.Read(entityType, objectID)
+	if err != nil {
+		return 0, 0, err
 	}
 
-	attributes, ok := data["attributes"].(map[string]interface{})
+	version, ok := ResourceVersion(res)
 	if !ok {
-		return 0, fmt.Errorf("invalid response format")
+		version = -1
 	}
 
-	permissionValue, ok := attributes["permission"]
+	permissionValue, ok := res.Attributes["permission"]
 	if !ok {
-		return 0, fmt.Errorf("permission field not found")
+		return 0, 0, fmt.Errorf("permission field not found")
 	}
 
 	var permValue int64
@@ -109,19 +109,19 @@ func (c *Client) GetPermissions(entityType, objectID string) (AuthPermission, er
 	case string:
 		permValue, err = strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			return 0, fmt.Errorf("invalid permission value: %v", err)
+			return 0, 0, fmt.Errorf("invalid permission value: %v", err)
 		}
 	case float64:
 		permValue = int64(v)
 	default:
-		return 0, fmt.Errorf("invalid permission value type")
+		return 0, 0, fmt.Errorf("invalid permission value type")
 	}
 
-	return AuthPermission(permValue), nil
+	return AuthPermission(permValue), version, nil
 }
 
 func (c *Client) SetPermissions(entityType, objectID string, perm AuthPermission) error {
-	path := fmt.Sprintf("%s/%s", entityType, objectID)
+	path := fmt.Sprintf("api/%s/%s", entityType, objectID)
 	data := map[string]interface{}{
 		"data": map[string]interface{}{
 			"type": entityType,
@@ -132,6 +132,19 @@ func (c *Client) SetPermissions(entityType, objectID string, perm AuthPermission
 		},
 	}
 
+	after := c.record(Change{Kind: ChangePermission, Type: entityType, ID: objectID})
 	_, err := c.PatchResource(path, data)
+	after(err)
 	return err
 }
+
+// SetPermissionsIfVersion sets the permission of a resource only if it is
+// still at the expected version. A negative version skips the check.
+func (c *Client) SetPermissionsIfVersion(entityType, objectID string, perm AuthPermission, expected int64) error {
+	if expected >= 0 {
+		if err := c.CheckVersion(entityType, objectID, expected); err != nil {
+			return err
+		}
+	}
+	return c.SetPermissions(entityType, objectID, perm)
+}
@@ -0,0 +1,113 @@
+// api/permissions_test.go
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// permissionServer serves one user_account record, u1, and records the
+// requests it gets as "METHOD path".
+func permissionServer(t *testing.T, permission string) (*Client, *[]string, *[]byte) {
+	t.Helper()
+	var requests []string
+	var patched []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path != "/api/user_account/u1" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPatch {
+			patched, _ = io.ReadAll(r.Body)
+		}
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		io.WriteString(w, `{"data":{"type":"user_account","id":"u1","attributes":{"version":3,"permission":`+permission+`}}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL+"/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, &requests, &patched
+}
+
+// Permissions are read and written through the JSON:API endpoint of the
+// resource, /api/<type>/<id>, like every other attribute.
+func TestPermissionEndpoints(t *testing.T) {
+	client, requests, patched := permissionServer(t, "2097151")
+
+	perm, err := client.GetPermissions("user_account", "u1")
+	if err != nil {
+		t.Fatalf("GetPermissions failed: %v", err)
+	}
+	if perm != 2097151 {
+		t.Errorf("GetPermissions = %d, want 2097151", perm)
+	}
+
+	if err := client.SetPermissions("user_account", "u1", GuestRead|UserRead); err != nil {
+		t.Fatalf("SetPermissions failed: %v", err)
+	}
+	var body struct {
+		Data struct {
+			Type       string                 `json:"type"`
+			ID         string                 `json:"id"`
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(*patched, &body); err != nil {
+		t.Fatalf("SetPermissions sent %s: %v", *patched, err)
+	}
+	if body.Data.Type != "user_account" || body.Data.ID != "u1" || body.Data.Attributes["permission"] != fmt.Sprint(int64(GuestRead|UserRead)) {
+		t.Errorf("SetPermissions sent %s", *patched)
+	}
+
+	want := "GET /api/user_account/u1,PATCH /api/user_account/u1"
+	if got := strings.Join(*requests, ","); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+}
+
+func TestGetVersionedPermissions(t *testing.T) {
+	for _, permission := range []string{"6", `"6"`} {
+		client, _, _ := permissionServer(t, permission)
+		perm, version, err := client.GetVersionedPermissions("user_account", "u1")
+		if err != nil || perm != 6 || version != 3 {
+			t.Errorf("with permission %s: GetVersionedPermissions = %d, %d, %v, want 6, 3, nil", permission, perm, version, err)
+		}
+	}
+
+	client, _, _ := permissionServer(t, `"all"`)
+	if _, _, err := client.GetVersionedPermissions("user_account", "u1"); err == nil {
+		t.Error("GetVersionedPermissions of a non-numeric permission succeeded, want an error")
+	}
+}
+
+func TestSetPermissionsIfVersion(t *testing.T) {
+	client, requests, _ := permissionServer(t, "6")
+
+	err := client.SetPermissionsIfVersion("user_account", "u1", GuestRead, 2)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != 2 || conflict.Actual != 3 {
+		t.Fatalf("SetPermissionsIfVersion at a stale version = %v, want a conflict", err)
+	}
+	if got := strings.Join(*requests, ","); got != "GET /api/user_account/u1" {
+		t.Errorf("a conflicting write sent %s, want only the version check", got)
+	}
+
+	*requests = nil
+	if err := client.SetPermissionsIfVersion("user_account", "u1", GuestRead, 3); err != nil {
+		t.Fatalf("SetPermissionsIfVersion at the current version failed: %v", err)
+	}
+	if got := strings.Join(*requests, ","); got != "GET /api/user_account/u1,PATCH /api/user_account/u1" {
+		t.Errorf("requests = %s", got)
+	}
+}
@@ -0,0 +1,77 @@
+// api/raw.go
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RawResponse is the answer to a request sent with Raw, whatever its status.
+type RawResponse struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// Raw sends a request to any path of the server with the client's headers,
+// for endpoints that have no method of their own. The path is relative to
+// the base URL, with or without a leading slash, and may carry a query,
+// which query is added to. header overrides the client's headers. Error
+// statuses are returned as responses rather than errors.
+func (c *Client) Raw(method, path string, query url.Values, header http.Header, body []byte) (*RawResponse, error) {
+	rel, err := url.Parse(strings.TrimLeft(path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+	if rel.IsAbs() || rel.Host != "" {
+		return nil, fmt.Errorf("invalid path %q: give a path on the server, not a URL", path)
+	}
+	values := rel.Query()
+	for key, list := range query {
+		for _, value := range list {
+			values.Add(key, value)
+		}
+	}
+	rel.RawQuery = values.Encode()
+
+	base := *c.BaseURL
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	u := base.ResolveReference(rel)
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(strings.ToUpper(method), u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range c.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &RawResponse{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: data}, nil
+}
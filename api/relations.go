@@ -33,12 +33,14 @@ func (c *Client) GetRelationship(resourceType, id, relation string) (*models.Doc
 }
 
 func (c *Client) UpdateRelationship(resourceType, id, relation string, data interface{}) (*models.Document, error) {
+	after := c.record(Change{Kind: ChangeRelation, Type: resourceType, ID: id, Relations: []string{relation}})
 	path := fmt.Sprintf("api/%s/%s/relationships/%s", resourceType, id, relation)
 	doc := &models.Document{
 		Data: data,
 	}
 	var respDoc models.Document
 	err := c.patch(path, doc, &respDoc)
+	after(err)
 	if err != nil {
 		return nil, err
 	}
@@ -46,12 +48,14 @@ func (c *Client) UpdateRelationship(resourceType, id, relation string, data inte
 }
 
 func (c *Client) AddToRelationship(resourceType, id, relation string, data interface{}) (*models.Document, error) {
+	after := c.record(Change{Kind: ChangeRelation, Type: resourceType, ID: id, Relations: []string{relation}})
 	path := fmt.Sprintf("api/%s/%s/relationships/%s", resourceType, id, relation)
 	doc := &models.Document{
 		Data: data,
 	}
 	var respDoc models.Document
 	err := c.post(path, doc, &respDoc)
+	after(err)
 	if err != nil {
 		return nil, err
 	}
@@ -59,11 +63,13 @@ func (c *Client) AddToRelationship(resourceType, id, relation string, data inter
 }
 
 func (c *Client) DeleteFromRelationship(resourceType, id, relation string, data interface{}) error {
+	after := c.record(Change{Kind: ChangeRelation, Type: resourceType, ID: id, Relations: []string{relation}})
 	path := fmt.Sprintf("api/%s/%s/relationships/%s", resourceType, id, relation)
 	doc := &models.Document{
 		Data: data,
 	}
 	err := c.deleteWithBody(path, doc)
+	after(err)
 	if err != nil {
 		return err
 	}
@@ -26,9 +26,7 @@ func (c *Client) Create(resource *models.Resource) (*models.Resource, error) {
 }
 
 func (c *Client) Read(resourceType, id string) (*models.Resource, error) {
-	path := fmt.Sprintf("api/%s/%s", resourceType, id)
-	var respDoc models.Document
-	err := c.get(path, nil, &respDoc)
+	respDoc, err := c.ReadDocument(resourceType, id, "")
 	if err != nil {
 		return nil, err
 	}
@@ -39,16 +37,42 @@ func (c *Client) Read(resourceType, id string) (*models.Resource, error) {
 	return res, nil
 }
 
+// ReadDocument fetches a resource as a compound document, with the
+// comma-separated relations in include sent as daptin's included_relations.
+func (c *Client) ReadDocument(resourceType, id, include string) (*models.Document, error) {
+	path := fmt.Sprintf("api/%s/%s", resourceType, id)
+	queryParams := make(map[string]string)
+	if include != "" {
+		queryParams["included_relations"] = include
+	}
+	var respDoc models.Document
+	err := c.get(path, queryParams, &respDoc)
+	if err != nil {
+		return nil, err
+	}
+	return &respDoc, nil
+}
+
 func (c *Client) Update(resource *models.Resource) (*models.Resource, error) {
 	if resource.ID == "" {
 		return nil, fmt.Errorf("resource ID is required for update")
 	}
+	change := Change{Kind: ChangeUpdate, Type: resource.Type, ID: resource.ID}
+	for name := range resource.Attributes {
+		change.Attributes = append(change.Attributes, name)
+	}
+	for name := range resource.Relationships {
+		change.Relations = append(change.Relations, name)
+	}
+	after := c.record(change)
+
 	path := fmt.Sprintf("api/%s/%s", resource.Type, resource.ID)
 	doc := &models.Document{
 		Data: resource,
 	}
 	var respDoc models.Document
 	err := c.patch(path, doc, &respDoc)
+	after(err)
 	if err != nil {
 		return nil, err
 	}
@@ -60,8 +84,10 @@ func (c *Client) Update(resource *models.Resource) (*models.Resource, error) {
 }
 
 func (c *Client) Delete(resourceType, id string) error {
+	after := c.record(Change{Kind: ChangeDelete, Type: resourceType, ID: id})
 	path := fmt.Sprintf("api/%s/%s", resourceType, id)
 	err := c.delete(path)
+	after(err)
 	if err != nil {
 		return err
 	}
@@ -80,3 +106,16 @@ func parseSingleResource(data interface{}) (*models.Resource, error) {
 	}
 	return &resource, nil
 }
+
+func parseResourceList(data interface{}) ([]*models.Resource, error) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var resources []*models.Resource
+	err = json.Unmarshal(dataBytes, &resources)
+	if err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
@@ -0,0 +1,302 @@
+// api/validate.go
+
+package api
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation is a value that breaks a constraint of the schema.
+type Violation struct {
+	Column  string
+	Message string
+}
+
+// ValidationError lists every violation found in a payload.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = fmt.Sprintf("%s: %s", v.Column, v.Message)
+	}
+	if len(lines) == 1 {
+		return "validation failed: " + lines[0]
+	}
+	return fmt.Sprintf("%d validation errors:\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+// StandardColumns are the columns daptin adds to every entity and maintains
+// itself.
+var StandardColumns = map[string]struct{}{
+	"id":           {},
+	"version":      {},
+	"created_at":   {},
+	"updated_at":   {},
+	"reference_id": {},
+	"permission":   {},
+}
+
+// Validate checks attributes against the model before they are sent: unknown
+// columns, null or missing required columns, values outside a column's
+// options, strings longer than the column and the entity's validation tags.
+// Missing columns are only reported when creating; partial updates may leave
+// them out. It returns a *ValidationError, or nil when the attributes are
+// valid.
+func (t *TableInfo) Validate(attrs map[string]interface{}, create bool) error {
+	var violations []Violation
+	for _, name := range sortedAttributeKeys(attrs) {
+		col, ok := t.ColumnModel[name]
+		switch {
+		case !ok:
+			violations = append(violations, Violation{name, "unknown column"})
+		case col.JsonApi != "":
+			violations = append(violations, Violation{name, fmt.Sprintf("is a %s relation, not an attribute", col.JsonApi)})
+		default:
+			violations = append(violations, checkValue(name, col, attrs[name])...)
+		}
+	}
+
+	if create {
+		names := make([]string, 0, len(t.ColumnModel))
+		for name := range t.ColumnModel {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			col := t.ColumnModel[name]
+			if _, ok := attrs[name]; ok || !isRequiredColumn(name, col) {
+				continue
+			}
+			violations = append(violations, Violation{name, "is required"})
+		}
+	}
+
+	violations = append(violations, checkTags(t.Validations, attrs, create)...)
+	return violationsError(violations)
+}
+
+// Validate checks the inputs of an action against its input fields and
+// validation tags, like TableInfo.Validate does for attributes. The
+// <type>_id input naming the instance the action runs on is not one of the
+// input fields and is accepted as it is.
+func (a *Action) Validate(inputs map[string]interface{}) error {
+	fields := make(map[string]ColumnInfo, len(a.InFields))
+	for _, field := range a.InFields {
+		if field.ColumnName != "" {
+			fields[field.ColumnName] = field
+		}
+		if field.Name != "" {
+			fields[field.Name] = field
+		}
+	}
+
+	var violations []Violation
+	for _, name := range sortedAttributeKeys(inputs) {
+		field, ok := fields[name]
+		if !ok && a.OnType != "" && name == a.OnType+"_id" {
+			continue
+		}
+		if !ok {
+			violations = append(violations, Violation{name, "unknown input"})
+			continue
+		}
+		violations = append(violations, checkValue(name, field, inputs[name])...)
+	}
+	violations = append(violations, checkTags(a.Validations, inputs, true)...)
+	return violationsError(violations)
+}
+
+// violationsError returns the violations as a *ValidationError, reporting
+// each once: a required column can be missing from the model's view and its
+// required tag alike.
+func violationsError(violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	seen := make(map[Violation]bool, len(violations))
+	unique := violations[:0]
+	for _, v := range violations {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return &ValidationError{Violations: unique}
+}
+
+func sortedAttributeKeys(attrs map[string]interface{}) []string {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isRequiredColumn reports whether a new record needs a value for a column:
+// it is a non-nullable attribute without a default that daptin does not fill
+// in itself.
+func isRequiredColumn(name string, col ColumnInfo) bool {
+	if _, ok := StandardColumns[name]; ok {
+		return false
+	}
+	return !col.IsNullable && col.DefaultValue == "" && col.JsonApi == "" &&
+		!col.IsPrimaryKey && !col.IsAutoIncrement && !col.ExcludeFromApi && col.ColumnType != "hidden"
+}
+
+// checkValue checks a value against the nullability, options and length of
+// its column.
+func checkValue(name string, col ColumnInfo, value interface{}) []Violation {
+	if value == nil {
+		if !col.IsNullable && !col.IsPrimaryKey {
+			return []Violation{{name, "cannot be null"}}
+		}
+		return nil
+	}
+
+	var violations []Violation
+	if len(col.Options) > 0 {
+		allowed := make([]string, len(col.Options))
+		found := false
+		for i, option := range col.Options {
+			allowed[i] = fmt.Sprint(option.Value)
+			if allowed[i] == fmt.Sprint(value) {
+				found = true
+			}
+		}
+		if !found {
+			violations = append(violations, Violation{name, fmt.Sprintf("%v is not one of %s", value, strings.Join(allowed, ", "))})
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		if limit, ok := columnLength(col.DataType); ok && utf8.RuneCountInString(s) > limit {
+			violations = append(violations, Violation{name, fmt.Sprintf("is %d characters long, the column holds at most %d", utf8.RuneCountInString(s), limit)})
+		}
+	}
+	return violations
+}
+
+var lengthPattern = regexp.MustCompile(`^(?i)(?:var)?char\((\d+)\)`)
+
+// columnLength returns the maximum length of a varchar or char data type.
+func columnLength(dataType string) (int, bool) {
+	match := lengthPattern.FindStringSubmatch(strings.TrimSpace(dataType))
+	if match == nil {
+		return 0, false
+	}
+	limit, err := strconv.Atoi(match[1])
+	return limit, err == nil
+}
+
+// checkTags applies validation tags in the comma-separated form daptin uses,
+// e.g. "required,email" or "min=3,max=20". Values are only checked when
+// present, except for required when create is set. Unknown tags are left to
+// the server.
+func checkTags(tags []ColumnTag, attrs map[string]interface{}, create bool) []Violation {
+	var violations []Violation
+	for _, tag := range tags {
+		value, present := attrs[tag.ColumnName]
+		for _, rule := range strings.Split(tag.Tags, ",") {
+			rule = strings.TrimSpace(rule)
+			name, param, _ := strings.Cut(rule, "=")
+			if name == "required" {
+				if (create && !present) || (present && isEmptyValue(value)) {
+					violations = append(violations, Violation{tag.ColumnName, "is required"})
+				}
+				continue
+			}
+			if !present || value == nil {
+				continue
+			}
+			if message := checkRule(name, param, value); message != "" {
+				violations = append(violations, Violation{tag.ColumnName, message})
+			}
+		}
+	}
+	return violations
+}
+
+// checkRule checks one validation rule and describes the violation, or
+// returns "" when the value passes or the rule is unknown.
+func checkRule(name, param string, value interface{}) string {
+	text := fmt.Sprint(value)
+	switch name {
+	case "email":
+		if address, err := mail.ParseAddress(text); err != nil || address.Address != text {
+			return "must be a valid email address"
+		}
+	case "url", "uri":
+		if u, err := url.Parse(text); err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be a valid URL"
+		}
+	case "numeric", "number":
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return "must be a number"
+		}
+	case "oneof":
+		for _, allowed := range strings.Fields(param) {
+			if allowed == text {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.Join(strings.Fields(param), ", "))
+	case "min", "max", "len", "gte", "lte":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return ""
+		}
+		size, unit := measure(value)
+		switch {
+		case (name == "min" || name == "gte") && size < limit:
+			return fmt.Sprintf("must be at least %s%s", param, unit)
+		case (name == "max" || name == "lte") && size > limit:
+			return fmt.Sprintf("must be at most %s%s", param, unit)
+		case name == "len" && size != limit:
+			return fmt.Sprintf("must be exactly %s%s", param, unit)
+		}
+	}
+	return ""
+}
+
+// measure returns the size a min, max or len rule compares: the value of a
+// number, the length of a string or the number of items of a list.
+func measure(value interface{}) (float64, string) {
+	switch v := value.(type) {
+	case float64:
+		return v, ""
+	case int64:
+		return float64(v), ""
+	case int:
+		return float64(v), ""
+	case string:
+		return float64(utf8.RuneCountInString(v)), " characters"
+	case []interface{}:
+		return float64(len(v)), " items"
+	case map[string]interface{}:
+		return float64(len(v)), " items"
+	}
+	return 0, ""
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
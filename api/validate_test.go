@@ -0,0 +1,262 @@
+// api/validate_test.go
+
+package api
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validationModel() *TableInfo {
+	return &TableInfo{
+		TableName: "user_account",
+		ColumnModel: map[string]ColumnInfo{
+			"id":           {ColumnType: "id", DataType: "INTEGER"},
+			"reference_id": {ColumnType: "alias", DataType: "varchar(40)"},
+			"version":      {ColumnType: "measurement", DataType: "int(11)"},
+			"created_at":   {ColumnType: "datetime", DataType: "timestamp"},
+			"permission":   {ColumnType: "value", DataType: "int(11)"},
+			"name":         {ColumnType: "label", DataType: "varchar(10)"},
+			"email":        {ColumnType: "email", DataType: "varchar(80)", IsNullable: true},
+			"status": {ColumnType: "label", DataType: "varchar(20)", IsNullable: true, Options: []ValueOptions{
+				{Value: "active", Label: "Active"}, {Value: "banned", Label: "Banned"},
+			}},
+			"confirmed":    {ColumnType: "truefalse", DataType: "bool", DefaultValue: "false"},
+			"age":          {ColumnType: "measurement", DataType: "int(11)", IsNullable: true},
+			"secret":       {ColumnType: "hidden", DataType: "varchar(10)"},
+			"seq":          {ColumnType: "measurement", DataType: "int(11)", IsAutoIncrement: true},
+			"internal":     {ColumnType: "label", DataType: "varchar(10)", ExcludeFromApi: true},
+			"usergroup_id": {JsonApi: "hasMany", Type: "usergroup"},
+		},
+		Validations: []ColumnTag{
+			{ColumnName: "email", Tags: "email"},
+			{ColumnName: "age", Tags: "min=18, max=99"},
+		},
+	}
+}
+
+// violationStrings returns the violations of a validation error as
+// "column: message", or nil when err is nil.
+func violationStrings(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error %v is a %T, want a *ValidationError", err, err)
+	}
+	var out []string
+	for _, v := range validationErr.Violations {
+		out = append(out, v.Column+": "+v.Message)
+	}
+	return out
+}
+
+func TestTableInfoValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		attrs  map[string]interface{}
+		create bool
+		want   []string
+	}{
+		{"valid create", map[string]interface{}{"name": "Al", "email": "al@x.com"}, true, nil},
+		{"missing required column on create", map[string]interface{}{"email": "al@x.com"}, true, []string{"name: is required"}},
+		{"missing columns on update", map[string]interface{}{}, false, nil},
+		{"standard columns are accepted", map[string]interface{}{"name": "Al", "id": "1", "version": float64(2)}, true, nil},
+		{"unknown column", map[string]interface{}{"nickname": "al"}, false, []string{"nickname: unknown column"}},
+		{"relation as attribute", map[string]interface{}{"usergroup_id": "g1"}, false, []string{"usergroup_id: is a hasMany relation, not an attribute"}},
+		{"null in a required column", map[string]interface{}{"name": nil}, false, []string{"name: cannot be null"}},
+		{"null in a nullable column", map[string]interface{}{"email": nil}, false, nil},
+		{"null in a column with a default", map[string]interface{}{"confirmed": nil}, false, []string{"confirmed: cannot be null"}},
+		{"option", map[string]interface{}{"status": "banned"}, false, nil},
+		{"value outside the options", map[string]interface{}{"status": "gone"}, false, []string{"status: gone is not one of active, banned"}},
+		{"string at the column length", map[string]interface{}{"name": "abcdéfghij"}, false, nil},
+		{"string over the column length", map[string]interface{}{"name": "abcdefghijk"}, false, []string{"name: is 11 characters long, the column holds at most 10"}},
+		{"email tag", map[string]interface{}{"email": "not-an-email"}, false, []string{"email: must be a valid email address"}},
+		{"min tag", map[string]interface{}{"age": float64(12)}, false, []string{"age: must be at least 18"}},
+		{"max tag", map[string]interface{}{"age": int64(100)}, false, []string{"age: must be at most 99"}},
+		{"tags skip null values", map[string]interface{}{"age": nil, "email": nil}, false, nil},
+		{
+			// Column checks come first, then required columns, then tags
+			"violations in order",
+			map[string]interface{}{"status": "gone", "email": "x", "age": float64(1), "nickname": "al"},
+			true,
+			[]string{
+				"nickname: unknown column",
+				"status: gone is not one of active, banned",
+				"name: is required",
+				"email: must be a valid email address",
+				"age: must be at least 18",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := violationStrings(t, validationModel().Validate(tt.attrs, tt.create))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Validate(%v, %v) = %q, want %q", tt.name, tt.attrs, tt.create, got, tt.want)
+		}
+	}
+}
+
+func TestTableInfoValidateRequiredTag(t *testing.T) {
+	model := validationModel()
+	model.Validations = append(model.Validations, ColumnTag{ColumnName: "name", Tags: "required,min=2"})
+
+	tests := []struct {
+		attrs  map[string]interface{}
+		create bool
+		want   []string
+	}{
+		// The tag and the column report the same missing value once
+		{map[string]interface{}{}, true, []string{"name: is required"}},
+		{map[string]interface{}{}, false, nil},
+		{map[string]interface{}{"name": ""}, false, []string{"name: is required", "name: must be at least 2 characters"}},
+		{map[string]interface{}{"name": "A"}, false, []string{"name: must be at least 2 characters"}},
+		{map[string]interface{}{"name": "Al"}, true, nil},
+	}
+	for _, tt := range tests {
+		got := violationStrings(t, model.Validate(tt.attrs, tt.create))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Validate(%v, %v) = %q, want %q", tt.attrs, tt.create, got, tt.want)
+		}
+	}
+}
+
+func TestActionValidate(t *testing.T) {
+	action := &Action{
+		OnType: "user_account",
+		InFields: []ColumnInfo{
+			{Name: "reason", ColumnName: "reason", ColumnType: "label", DataType: "varchar(10)"},
+		},
+	}
+	tests := []struct {
+		inputs map[string]interface{}
+		want   []string
+	}{
+		{map[string]interface{}{"reason": "spam"}, nil},
+		{map[string]interface{}{"reason": "spam", "user_account_id": "u1"}, nil},
+		{map[string]interface{}{"reason": "spam", "usergroup_id": "g1"}, []string{"usergroup_id: unknown input"}},
+		{map[string]interface{}{"reason": "far too long", "user_account_id": "u1"}, []string{"reason: is 12 characters long, the column holds at most 10"}},
+	}
+	for _, tt := range tests {
+		got := violationStrings(t, action.Validate(tt.inputs))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Validate(%v) = %q, want %q", tt.inputs, got, tt.want)
+		}
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	one := &ValidationError{Violations: []Violation{{"name", "is required"}}}
+	if got, want := one.Error(), "validation failed: name: is required"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	two := &ValidationError{Violations: []Violation{{"name", "is required"}, {"age", "must be a number"}}}
+	if got, want := two.Error(), "2 validation errors:\n  name: is required\n  age: must be a number"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	tags := []ColumnTag{
+		{ColumnName: "name", Tags: "required"},
+		{ColumnName: "email", Tags: " required , email "},
+		{ColumnName: "code", Tags: "alphanum,len=3,unknown=1"},
+	}
+	tests := []struct {
+		attrs  map[string]interface{}
+		create bool
+		want   []Violation
+	}{
+		{map[string]interface{}{"name": "Al", "email": "al@x.com"}, true, nil},
+		{map[string]interface{}{}, true, []Violation{{"name", "is required"}, {"email", "is required"}}},
+		{map[string]interface{}{}, false, nil},
+		{map[string]interface{}{"name": nil}, false, []Violation{{"name", "is required"}}},
+		{map[string]interface{}{"name": ""}, false, []Violation{{"name", "is required"}}},
+		{map[string]interface{}{"name": []interface{}{}}, false, []Violation{{"name", "is required"}}},
+		{map[string]interface{}{"name": float64(0)}, false, nil},
+		{map[string]interface{}{"email": "bad"}, false, []Violation{{"email", "must be a valid email address"}}},
+		{map[string]interface{}{"email": nil}, false, []Violation{{"email", "is required"}}},
+		{map[string]interface{}{"code": "abc"}, false, nil},
+		{map[string]interface{}{"code": "ab"}, false, []Violation{{"code", "must be exactly 3 characters"}}},
+		{map[string]interface{}{"code": nil}, false, nil},
+	}
+	for _, tt := range tests {
+		got := checkTags(tags, tt.attrs, tt.create)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("checkTags(%v, %v) = %v, want %v", tt.attrs, tt.create, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRule(t *testing.T) {
+	tests := []struct {
+		rule  string
+		value interface{}
+		want  string
+	}{
+		{"email", "al@x.com", ""},
+		{"email", "Al <al@x.com>", "must be a valid email address"},
+		{"email", "al@", "must be a valid email address"},
+		{"email", "", "must be a valid email address"},
+		{"url", "https://x.com/a?b=c", ""},
+		{"uri", "ftp://files.x.com", ""},
+		{"url", "x.com", "must be a valid URL"},
+		{"url", "http://", "must be a valid URL"},
+		{"url", "::", "must be a valid URL"},
+		{"numeric", "3.5", ""},
+		{"numeric", float64(3), ""},
+		{"number", "-1e3", ""},
+		{"numeric", "three", "must be a number"},
+		{"oneof=red green blue", "green", ""},
+		{"oneof=red  green", "blue", "must be one of red, green"},
+		{"oneof=1 2", float64(2), ""},
+		{"min=3", "abc", ""},
+		{"min=3", "ab", "must be at least 3 characters"},
+		{"min=3", "日本", "must be at least 3 characters"},
+		{"min=3", float64(2.5), "must be at least 3"},
+		{"gte=0", int64(-1), "must be at least 0"},
+		{"max=2", []interface{}{1, 2}, ""},
+		{"max=2", []interface{}{1, 2, 3}, "must be at most 2 items"},
+		{"max=1", map[string]interface{}{"a": 1, "b": 2}, "must be at most 1 items"},
+		{"lte=10", 11, "must be at most 10"},
+		{"len=2", "ab", ""},
+		{"len=2", "abc", "must be exactly 2 characters"},
+		{"min=x", "a", ""},
+		{"min", "a", ""},
+		{"alpha", "123", ""},
+		{"min=1", true, "must be at least 1"},
+	}
+	for _, tt := range tests {
+		name, param, _ := strings.Cut(tt.rule, "=")
+		if got := checkRule(name, param, tt.value); got != tt.want {
+			t.Errorf("checkRule(%q, %#v) = %q, want %q", tt.rule, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestColumnLength(t *testing.T) {
+	tests := []struct {
+		dataType string
+		want     int
+		ok       bool
+	}{
+		{"varchar(80)", 80, true},
+		{"VARCHAR(20)", 20, true},
+		{"char(2)", 2, true},
+		{" varchar(5) ", 5, true},
+		{"text", 0, false},
+		{"int(11)", 0, false},
+		{"varchar", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := columnLength(tt.dataType)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("columnLength(%q) = %d, %v, want %d, %v", tt.dataType, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// api/version.go
+
+package api
+
+import (
+	"dcli/models"
+	"fmt"
+)
+
+// ConflictError reports that a resource was modified since the version a
+// write was based on.
+type ConflictError struct {
+	Type     string
+	ID       string
+	Expected int64
+	Actual   int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict: %s %s was modified, expected version %d but the server has version %d",
+		e.Type, e.ID, e.Expected, e.Actual)
+}
+
+// ResourceVersion returns the value of the version column of a resource.
+func ResourceVersion(res *models.Resource) (int64, bool) {
+	if res == nil {
+		return 0, false
+	}
+	version, ok := intValue(res.Attributes["version"])
+	return int64(version), ok
+}
+
+// CheckVersion reads a resource and returns a *ConflictError when its
+// version is not the expected one. daptin has no conditional writes, so the
+// check narrows the window for lost updates rather than closing it.
+func (c *Client) CheckVersion(resourceType, id string, expected int64) error {
+	current, err := c.Read(resourceType, id)
+	if err != nil {
+		return err
+	}
+	actual, ok := ResourceVersion(current)
+	if !ok {
+		return fmt.Errorf("%s %s has no version attribute to compare", resourceType, id)
+	}
+	if actual != expected {
+		return &ConflictError{Type: resourceType, ID: id, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// UpdateIfVersion updates a resource only if it is still at the expected
+// version.
+func (c *Client) UpdateIfVersion(resource *models.Resource, expected int64) (*models.Resource, error) {
+	if err := c.CheckVersion(resource.Type, resource.ID, expected); err != nil {
+		return nil, err
+	}
+	return c.Update(resource)
+}
@@ -0,0 +1,575 @@
+// cmd/apply.go
+
+package main
+
+import (
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// manifestExtensions are the file extensions apply reads from directories.
+var manifestExtensions = map[string]struct{}{
+	".json":   {},
+	".yaml":   {},
+	".yml":    {},
+	".ndjson": {},
+	".jsonl":  {},
+}
+
+// applyStep is one planned change of an apply run.
+type applyStep struct {
+	Action  string   `json:"action"` // create, update, unchanged or delete
+	Type    string   `json:"type"`
+	ID      string   `json:"id,omitempty"`
+	Match   string   `json:"match,omitempty"`
+	Source  string   `json:"source,omitempty"`
+	Changes []string `json:"changes,omitempty"`
+	Error   string   `json:"error,omitempty"`
+
+	resource *models.Resource // What to send, for create and update
+	version  int64            // Version of the record the update was planned against, or -1
+}
+
+func applyCommand(client *api.Client, args []string) {
+	applyCmd := flag.NewFlagSet("apply", flag.ExitOnError)
+	registerGlobalFlags(applyCmd)
+	path := applyCmd.String("f", "", "Manifest file, or directory of .json, .yaml and .ndjson manifests; '-' for stdin")
+	resourceType := applyCmd.String("type", "", "Resource type of manifests that do not declare one")
+	key := applyCmd.String("key", "", "Comma-separated identity columns to match existing records by, unless a manifest declares meta.identity")
+	prune := applyCmd.Bool("prune", false, "Delete records of the applied types that no manifest matches")
+	yes := applyCmd.Bool("yes", false, "Prune without asking for confirmation")
+	noValidate := applyCmd.Bool("no-validate", false, "Send the manifests without checking them against the entity models")
+	applyCmd.Parse(args)
+
+	if *path == "" {
+		applyCmd.Usage()
+		os.Exit(1)
+	}
+
+	resources, sources, err := readManifests(*path, *resourceType)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to read manifests:", err)
+		os.Exit(1)
+	}
+
+	steps, err := planApply(client, resources, sources, parseColumnList(*key))
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to plan changes:", err)
+		os.Exit(1)
+	}
+	if *prune {
+		deletes, err := planPrune(client, steps)
+		if err != nil {
+			utils.ErrorLogger.Println("Failed to plan pruning:", err)
+			os.Exit(1)
+		}
+		steps = append(steps, deletes...)
+	}
+
+	if !*noValidate {
+		if err := validateSteps(client, steps); err != nil {
+			utils.ErrorLogger.Println("Invalid manifests:", err)
+			os.Exit(1)
+		}
+	}
+
+	printPlan(steps)
+	counts := countActions(steps)
+	if counts["delete"] > 0 && !*yes && !globalOptions.dryRun {
+		p := newPrompter()
+		answer, err := p.line(fmt.Sprintf("Delete %d records? [y/N] ", counts["delete"]))
+		if err != nil || !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			fmt.Fprintln(os.Stderr, "Apply cancelled.")
+			os.Exit(1)
+		}
+	}
+
+	executeSteps(client, steps)
+	if globalOptions.dryRun {
+		fmt.Fprintln(os.Stderr, "Dry run, nothing applied.")
+		return
+	}
+	render(applyView(steps))
+	for _, step := range steps {
+		if step.Error != "" {
+			os.Exit(1)
+		}
+	}
+}
+
+// readManifests reads the resources of a manifest file, of every manifest
+// in a directory tree, or of stdin. Resources without a type get
+// resourceType. Sources name the file and position of each resource.
+func readManifests(path, resourceType string) ([]*models.Resource, []string, error) {
+	files := []string{path}
+	if path != "-" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.IsDir() {
+			files = nil
+			err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if _, ok := manifestExtensions[strings.ToLower(filepath.Ext(file))]; ok && !entry.IsDir() {
+					files = append(files, file)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(files) == 0 {
+				return nil, nil, fmt.Errorf("no manifests found in %s", path)
+			}
+		}
+	}
+
+	var resources []*models.Resource
+	var sources []string
+	for _, file := range files {
+		items, err := readResourceFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, res := range items {
+			source := fmt.Sprintf("%s#%d", inputName(file), i+1)
+			if res.Type == "" {
+				res.Type = resourceType
+			}
+			if res.Type == "" {
+				return nil, nil, fmt.Errorf("%s has no type; declare one or pass -type", source)
+			}
+			resources = append(resources, res)
+			sources = append(sources, source)
+		}
+	}
+	return resources, sources, nil
+}
+
+// identityColumns returns the columns a manifest is matched by: those in its
+// meta.identity, or the default ones.
+func identityColumns(res *models.Resource, defaults []string) []string {
+	switch identity := res.Meta["identity"].(type) {
+	case string:
+		return parseColumnList(identity)
+	case []interface{}:
+		var columns []string
+		for _, column := range identity {
+			if name, ok := column.(string); ok {
+				columns = append(columns, name)
+			}
+		}
+		return columns
+	}
+	return defaults
+}
+
+// planApply matches every manifest to an existing record, by ID when it has
+// one and by its identity columns otherwise, and works out what to change.
+func planApply(client *api.Client, resources []*models.Resource, sources []string, key []string) ([]applyStep, error) {
+	matched := make(map[string]string)
+	var steps []applyStep
+	for i, res := range resources {
+		existing, match, err := findExisting(client, res, identityColumns(res, key))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sources[i], err)
+		}
+		if other, ok := matched[res.Type+" "+match]; ok {
+			return nil, fmt.Errorf("%s and %s both match %s %s", other, sources[i], res.Type, match)
+		}
+		matched[res.Type+" "+match] = sources[i]
+
+		step := applyStep{Type: res.Type, Match: match, Source: sources[i], version: -1}
+		if existing == nil {
+			step.Action = "create"
+			step.ID = res.ID
+			step.resource = res
+			step.Changes = sortedKeys(res.Attributes)
+			for name := range res.Relationships {
+				step.Changes = append(step.Changes, name)
+			}
+			steps = append(steps, step)
+			continue
+		}
+
+		step.ID = existing.ID
+		if version, ok := api.ResourceVersion(existing); ok {
+			step.version = version
+		}
+		changed, err := manifestChanges(client, res, existing)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sources[i], err)
+		}
+		step.Action = "unchanged"
+		if len(changed.Attributes)+len(changed.Relationships) > 0 {
+			step.Action = "update"
+			step.resource = changed
+			step.Changes = sortedKeys(changed.Attributes)
+			for name := range changed.Relationships {
+				step.Changes = append(step.Changes, name)
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// findExisting returns the record a manifest describes, or nil when there is
+// none yet, together with a description of how it was matched. The record
+// is read with the linkage of the relations the manifest sets, which the
+// server only returns for included relations.
+func findExisting(client *api.Client, res *models.Resource, identity []string) (*models.Resource, string, error) {
+	relations := make([]string, 0, len(res.Relationships))
+	for name := range res.Relationships {
+		relations = append(relations, name)
+	}
+	sort.Strings(relations)
+
+	if res.ID != "" {
+		match := "id=" + res.ID
+		existing, err := readLinked(client, res.Type, res.ID, relations)
+		if api.IsNotFound(err) {
+			return nil, match, nil
+		}
+		return existing, match, err
+	}
+	if len(identity) == 0 {
+		return nil, "", fmt.Errorf("no id and no identity key; pass -key or declare meta.identity")
+	}
+
+	conditions := make([]api.Condition, len(identity))
+	terms := make([]string, len(identity))
+	for i, column := range identity {
+		value, ok := res.Attributes[column]
+		if !ok || value == nil {
+			return nil, "", fmt.Errorf("identity column %s has no value", column)
+		}
+		conditions[i] = api.Condition{Column: column, Operator: "eq", Value: value}
+		terms[i] = column + "=" + plainValue(value)
+	}
+	match := strings.Join(terms, ",")
+
+	found, err := listMatching(client, res.Type, conditions, 2, relations...)
+	if err != nil {
+		return nil, match, err
+	}
+	switch len(found) {
+	case 0:
+		return nil, match, nil
+	case 1:
+		return found[0], match, nil
+	}
+	return nil, match, fmt.Errorf("%s matches more than one %s record", match, res.Type)
+}
+
+// listMatching returns up to limit records matching all conditions, with
+// the linkage of the given relations.
+func listMatching(client *api.Client, resourceType string, conditions []api.Condition, limit int, relations ...string) ([]*models.Resource, error) {
+	doc, err := client.List(resourceType, &api.ListOptions{
+		Page:    map[string]string{"size": fmt.Sprint(limit)},
+		Query:   conditions,
+		Include: strings.Join(relations, ","),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc.Resolve()
+}
+
+// manifestChanges returns a resource holding the attributes and
+// relationships of a manifest that differ from the existing record.
+func manifestChanges(client *api.Client, res, existing *models.Resource) (*models.Resource, error) {
+	changed := &models.Resource{Type: existing.Type, ID: existing.ID, Attributes: make(map[string]interface{})}
+	for key, value := range res.Attributes {
+		current, err := normalizeJSON(existing.Attributes[key])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := existing.Attributes[key]; !ok || !reflect.DeepEqual(current, value) {
+			changed.Attributes[key] = value
+		}
+	}
+
+	for name, rel := range res.Relationships {
+		linkage, err := resolveLinkage(client, rel)
+		if err != nil && !errors.Is(err, errNoMatch) {
+			return nil, fmt.Errorf("relationship %s: %w", name, err)
+		}
+		current, ok := existing.Relationships[name]
+		if err == nil && ok && current.Data != nil && sameLinkage(current.Identifiers(), linkageIdentifiers(linkage)) {
+			continue
+		}
+		if changed.Relationships == nil {
+			changed.Relationships = make(map[string]models.Relationship)
+		}
+		changed.Relationships[name] = rel
+	}
+	return changed, nil
+}
+
+// errNoMatch is returned when a relationship lookup matches no record yet.
+var errNoMatch = errors.New("no matching record")
+
+// resolveLinkage returns the linkage of a manifest relationship with every
+// identifier given as {"type": ..., "meta": {"match": {column: value}}}
+// replaced by the identifier of the record it matches.
+func resolveLinkage(client *api.Client, rel models.Relationship) (interface{}, error) {
+	resolve := func(item interface{}) (models.ResourceIdentifier, error) {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return models.ResourceIdentifier{}, fmt.Errorf("invalid resource identifier %v", item)
+		}
+		identifier := models.ResourceIdentifier{}
+		identifier.Type, _ = m["type"].(string)
+		identifier.ID, _ = m["id"].(string)
+		if identifier.Type == "" {
+			return identifier, fmt.Errorf("resource identifier without type")
+		}
+		if identifier.ID != "" {
+			return identifier, nil
+		}
+
+		meta, _ := m["meta"].(map[string]interface{})
+		lookup, _ := meta["match"].(map[string]interface{})
+		if len(lookup) == 0 {
+			return identifier, fmt.Errorf("%s identifier needs an id or meta.match", identifier.Type)
+		}
+		var conditions []api.Condition
+		var terms []string
+		for _, column := range sortedKeys(lookup) {
+			conditions = append(conditions, api.Condition{Column: column, Operator: "eq", Value: lookup[column]})
+			terms = append(terms, column+"="+plainValue(lookup[column]))
+		}
+		found, err := listMatching(client, identifier.Type, conditions, 2)
+		switch {
+		case err != nil:
+			return identifier, err
+		case len(found) == 0:
+			return identifier, fmt.Errorf("%s %s: %w", identifier.Type, strings.Join(terms, ","), errNoMatch)
+		case len(found) > 1:
+			return identifier, fmt.Errorf("%s %s matches more than one record", identifier.Type, strings.Join(terms, ","))
+		}
+		identifier.ID = found[0].ID
+		return identifier, nil
+	}
+
+	switch data := rel.Data.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		linkage := make([]models.ResourceIdentifier, 0, len(data))
+		for _, item := range data {
+			identifier, err := resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			linkage = append(linkage, identifier)
+		}
+		return linkage, nil
+	default:
+		return resolve(data)
+	}
+}
+
+func linkageIdentifiers(linkage interface{}) []models.ResourceIdentifier {
+	return models.Relationship{Data: linkage}.Identifiers()
+}
+
+// sameLinkage reports whether two linkages refer to the same records,
+// ignoring order.
+func sameLinkage(a, b []models.ResourceIdentifier) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	keys := func(identifiers []models.ResourceIdentifier) []string {
+		out := make([]string, len(identifiers))
+		for i, identifier := range identifiers {
+			out[i] = identifier.Type + "/" + identifier.ID
+		}
+		sort.Strings(out)
+		return out
+	}
+	return reflect.DeepEqual(keys(a), keys(b))
+}
+
+// planPrune plans the deletion of every record of the applied types that no
+// manifest matched.
+func planPrune(client *api.Client, steps []applyStep) ([]applyStep, error) {
+	kept := make(map[string]map[string]bool)
+	var types []string
+	for _, step := range steps {
+		if kept[step.Type] == nil {
+			kept[step.Type] = make(map[string]bool)
+			types = append(types, step.Type)
+		}
+		if step.ID != "" {
+			kept[step.Type][step.ID] = true
+		}
+	}
+
+	var deletes []applyStep
+	for _, resourceType := range types {
+		err := client.ListAll(resourceType, nil, func(page []*models.Resource) error {
+			for _, res := range page {
+				if !kept[resourceType][res.ID] {
+					kept[resourceType][res.ID] = true
+					deletes = append(deletes, applyStep{Action: "delete", Type: resourceType, ID: res.ID})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return deletes, nil
+}
+
+// validateSteps checks the attributes to create or update against the
+// entity models.
+func validateSteps(client *api.Client, steps []applyStep) error {
+	modelsByType := make(map[string]*api.TableInfo)
+	var failures []string
+	for _, step := range steps {
+		if step.resource == nil {
+			continue
+		}
+		model, ok := modelsByType[step.Type]
+		if !ok {
+			model = loadModel(client, step.Type)
+			modelsByType[step.Type] = model
+		}
+		if model == nil {
+			continue
+		}
+		if err := model.Validate(step.resource.Attributes, step.Action == "create"); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", step.Source, err))
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+func countActions(steps []applyStep) map[string]int {
+	counts := make(map[string]int)
+	for _, step := range steps {
+		counts[step.Action]++
+	}
+	return counts
+}
+
+// printPlan writes the planned changes to stderr, keeping stdout for the
+// results.
+func printPlan(steps []applyStep) {
+	view := &View{Title: "Plan", Columns: columnNames("Action", "Type", "ID", "Match", "Changes", "Source")}
+	for _, step := range steps {
+		if step.Action == "unchanged" {
+			continue
+		}
+		id := step.ID
+		if id == "" {
+			id = "(new)"
+		}
+		view.Rows = append(view.Rows, []string{step.Action, step.Type, id, step.Match, strings.Join(step.Changes, ", "), step.Source})
+	}
+	counts := countActions(steps)
+	view.Notes = []string{fmt.Sprintf("Plan: %d to create, %d to update, %d to delete, %d unchanged.",
+		counts["create"], counts["update"], counts["delete"], counts["unchanged"])}
+	if len(view.Rows) == 0 {
+		view.Columns = nil
+		view.Message = "No changes."
+	}
+	tableRenderer{decorate: true}.Render(os.Stderr, view)
+	fmt.Fprintln(os.Stderr)
+}
+
+// executeSteps carries out the plan in order, recording the outcome of each
+// step. Relationship lookups that matched nothing while planning are
+// resolved again, since earlier steps may have created the records. Updates
+// fail with a conflict when the record changed since it was planned.
+func executeSteps(client *api.Client, steps []applyStep) {
+	for i := range steps {
+		step := &steps[i]
+		var err error
+		switch step.Action {
+		case "create", "update":
+			if err = resolveRelationships(client, step.resource); err != nil {
+				break
+			}
+			if step.Action == "update" && step.version >= 0 {
+				_, err = client.UpdateIfVersion(step.resource, step.version)
+				break
+			}
+			if step.Action == "update" {
+				_, err = client.Update(step.resource)
+				break
+			}
+			var created *models.Resource
+			if created, err = client.Create(step.resource); err == nil {
+				step.ID = created.ID
+			}
+		case "delete":
+			err = client.Delete(step.Type, step.ID)
+		}
+		if err != nil && !isDryRun(err) {
+			step.Error = err.Error()
+		}
+	}
+}
+
+// resolveRelationships replaces the linkage of every relationship of a
+// resource with resolved resource identifiers.
+func resolveRelationships(client *api.Client, res *models.Resource) error {
+	for name, rel := range res.Relationships {
+		linkage, err := resolveLinkage(client, rel)
+		if err != nil {
+			return fmt.Errorf("relationship %s: %w", name, err)
+		}
+		res.Relationships[name] = models.Relationship{Data: linkage}
+	}
+	return nil
+}
+
+// applyView shows the outcome of every step of an apply run.
+func applyView(steps []applyStep) *View {
+	view := &View{
+		Columns: columnNames("Action", "Type", "ID", "Result"),
+		Data:    steps,
+	}
+	failed := 0
+	for _, step := range steps {
+		result := "ok"
+		switch {
+		case step.Error != "":
+			result = "failed: " + step.Error
+			failed++
+		case step.Action == "unchanged":
+			result = "-"
+		}
+		view.Rows = append(view.Rows, []string{step.Action, step.Type, step.ID, result})
+	}
+	counts := make(map[string]int)
+	for _, step := range steps {
+		if step.Error == "" {
+			counts[step.Action]++
+		}
+	}
+	view.Notes = []string{fmt.Sprintf("%d created, %d updated, %d deleted, %d unchanged, %d failed.",
+		counts["create"], counts["update"], counts["delete"], counts["unchanged"], failed)}
+	return view
+}
@@ -0,0 +1,91 @@
+// cmd/apply_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeManifest writes a manifest file for a test and returns its path.
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// An update planned against a record that changes before it is sent fails
+// with a conflict instead of overwriting the change.
+func TestApplyConflict(t *testing.T) {
+	f := newFakeServer(t)
+	f.add("user_account", "u1", map[string]interface{}{"email": "al@x.com", "name": "Al"}, nil)
+	manifest := writeManifest(t, `{"type":"user_account","attributes":{"email":"al@x.com","name":"Alice"}}`)
+
+	// The plan lists the record; the first read of it is the version check
+	concurrentChange(f, "user_account", "u1", "name", "Alfred")
+	stdout, stderr, code := f.run("", "apply", "-f", manifest, "-key", "email", "-o", "json")
+	if code != 1 || !strings.Contains(stdout, "conflict") {
+		t.Errorf("apply exited with %d, want a conflict: %s%s", code, stdout, stderr)
+	}
+	if patches := f.sent(http.MethodPatch); len(patches) > 0 {
+		t.Errorf("apply sent %v", patches)
+	}
+	if got := f.record("user_account", "u1").Attributes["name"]; got != "Alfred" {
+		t.Errorf("name = %v, want the concurrent change kept", got)
+	}
+}
+
+// Applying the same manifest twice changes nothing the second time,
+// relationships included.
+func TestApplyIdempotent(t *testing.T) {
+	f := newFakeServer(t)
+	f.add("usergroup", "g1", map[string]interface{}{"name": "staff"}, nil)
+	f.add("usergroup", "g2", map[string]interface{}{"name": "admins"}, nil)
+	f.add("user_account", "u1", map[string]interface{}{"email": "bo@x.com", "name": "Bo"}, map[string]interface{}{
+		"usergroup_id": []interface{}{map[string]interface{}{"type": "usergroup", "id": "g1"}},
+	})
+	manifest := writeManifest(t, `[
+		{"type":"user_account","attributes":{"email":"al@x.com","name":"Al"},
+		 "relationships":{"usergroup_id":{"data":[{"type":"usergroup","id":"g1"},{"type":"usergroup","meta":{"match":{"name":"admins"}}}]}}},
+		{"type":"user_account","id":"u1","attributes":{"name":"Bo"},
+		 "relationships":{"usergroup_id":{"data":[{"type":"usergroup","id":"g1"}]}}}
+	]`)
+
+	stdout, stderr, code := f.run("", "apply", "-f", manifest, "-key", "email", "-o", "json")
+	if code != 0 {
+		t.Fatalf("first apply exited with %d: %s%s", code, stdout, stderr)
+	}
+	if got := f.sent(http.MethodPost); len(got) != 1 {
+		t.Fatalf("first apply sent %v, want one create", got)
+	}
+
+	f.requests = nil
+	stdout, stderr, code = f.run("", "apply", "-f", manifest, "-key", "email", "-o", "json")
+	if code != 0 {
+		t.Fatalf("second apply exited with %d: %s%s", code, stdout, stderr)
+	}
+	var steps []applyStep
+	if err := json.Unmarshal([]byte(stdout), &steps); err != nil {
+		t.Fatalf("second apply printed %s: %v", stdout, err)
+	}
+	for _, step := range steps {
+		if step.Action != "unchanged" {
+			t.Errorf("second apply planned %s of %s %s (%v)", step.Action, step.Type, step.ID, step.Changes)
+		}
+	}
+	if len(steps) != 2 || !strings.Contains(stderr, "No changes.") {
+		t.Errorf("second apply planned %d steps: %s", len(steps), stderr)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPatch, http.MethodDelete} {
+		if got := f.sent(method); len(got) > 0 {
+			t.Errorf("second apply sent %v", got)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// cmd/bulk.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"dcli/api"
+	"dcli/models"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// bulkSampleSize is the number of affected records shown before a bulk
+// change.
+const bulkSampleSize = 5
+
+// bulkFlags holds the options of commands that change every record matching
+// a filter, or listed in a file, at once.
+type bulkFlags struct {
+	filters     *filterFlags
+	idsFrom     *string
+	yes         *bool
+	concurrency *int
+}
+
+// registerBulkFlags adds the bulk options of a command whose action is verb,
+// e.g. "delete".
+func registerBulkFlags(fs *flag.FlagSet, verb string) *bulkFlags {
+	return &bulkFlags{
+		filters:     registerFilterFlags(fs),
+		idsFrom:     fs.String("ids-from", "", fmt.Sprintf("File of IDs to %s, whitespace or comma separated; '-' for stdin", verb)),
+		yes:         fs.Bool("yes", false, fmt.Sprintf("%s%s without asking for confirmation", strings.ToUpper(verb[:1]), verb[1:])),
+		concurrency: fs.Int("concurrency", 8, fmt.Sprintf("Number of records to %s at the same time", verb)),
+	}
+}
+
+// enabled reports whether records were selected by filter or ID list.
+func (b *bulkFlags) enabled() bool {
+	return !b.filters.empty() || *b.idsFrom != ""
+}
+
+// targets returns the records a bulk change applies to: those matching the
+// filters, or those whose IDs are read from --ids-from. Records read by ID
+// only carry their type and ID.
+func (b *bulkFlags) targets(client *api.Client, resourceType string) ([]*models.Resource, error) {
+	if !b.filters.empty() && *b.idsFrom != "" {
+		return nil, fmt.Errorf("--ids-from cannot be combined with -filter or -where")
+	}
+	if *b.idsFrom != "" {
+		ids, err := readIDs(*b.idsFrom)
+		if err != nil {
+			return nil, err
+		}
+		targets := make([]*models.Resource, len(ids))
+		for i, id := range ids {
+			targets[i] = &models.Resource{Type: resourceType, ID: id}
+		}
+		return targets, nil
+	}
+
+	options := &api.ListOptions{}
+	if err := b.filters.apply(options); err != nil {
+		return nil, err
+	}
+	var targets []*models.Resource
+	seen := make(map[string]bool)
+	err := client.ListAll(resourceType, options, func(page []*models.Resource) error {
+		for _, res := range page {
+			if !seen[res.ID] {
+				seen[res.ID] = true
+				targets = append(targets, res)
+			}
+		}
+		return nil
+	})
+	return targets, err
+}
+
+// readIDs reads whitespace or comma separated IDs from a file, or from
+// stdin when path is "-", dropping duplicates.
+func readIDs(path string) ([]string, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		for _, id := range strings.Split(scanner.Text(), ",") {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no IDs found in %s", inputName(path))
+	}
+	return ids, nil
+}
+
+// previewBulk writes the number of records a bulk change affects and a
+// sample of them to stderr. Records known only by ID are read for the
+// sample.
+func previewBulk(client *api.Client, summary string, targets []*models.Resource) {
+	if len(targets) > bulkSampleSize {
+		summary += ", for example"
+	}
+	fmt.Fprintf(os.Stderr, "%s:\n", summary)
+	for i, res := range targets {
+		if i == bulkSampleSize {
+			fmt.Fprintf(os.Stderr, "  … and %d more\n", len(targets)-bulkSampleSize)
+			break
+		}
+		label := recordLabel(res)
+		if res.Attributes == nil {
+			read, err := client.Read(res.Type, res.ID)
+			switch {
+			case api.IsNotFound(err):
+				label = res.ID + "  (not found)"
+			case err == nil:
+				label = recordLabel(read)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "  %s\n", label)
+	}
+}
+
+// confirm asks on stderr whether to go ahead with a bulk change, unless
+// --yes or --dry-run is given. When the IDs came from stdin there is nothing
+// left to read an answer from, so it refuses.
+func (b *bulkFlags) confirm(question string) bool {
+	if *b.yes || globalOptions.dryRun {
+		return true
+	}
+	if *b.idsFrom == "-" {
+		fmt.Fprintln(os.Stderr, "The IDs were read from stdin, so there is no way to confirm; pass --yes.")
+		return false
+	}
+	answer, err := newPrompter().line(question + " [y/N] ")
+	return err == nil && (strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes"))
+}
+
+// run calls fn for every target, at most --concurrency at a time, showing
+// progress on a terminal, and returns the outcome of each. Under --dry-run,
+// targets are handled one after the other so that the printed requests do
+// not interleave.
+func (b *bulkFlags) run(targets []*models.Resource, fn func(res *models.Resource) error) []batchResult {
+	results := make([]batchResult, len(targets))
+	concurrency := *b.concurrency
+	if globalOptions.dryRun {
+		concurrency = 1
+	}
+	bar := startProgress(len(targets), stderrIsTerminal() && !globalOptions.dryRun)
+	forEachConcurrently(len(targets), concurrency, func(i int) {
+		res := targets[i]
+		results[i] = batchResult{Item: i + 1, Type: res.Type, ID: res.ID}
+		if err := fn(res); err != nil && !isDryRun(err) {
+			results[i].Error = err.Error()
+			bar.fail()
+			return
+		}
+		bar.advance()
+	})
+	bar.stop()
+	return results
+}
+
+// ndjsonFile writes records as NDJSON for concurrent workers, creating the
+// file on the first record.
+type ndjsonFile struct {
+	path      string
+	appending bool // Add to the records of an earlier run instead of replacing them
+
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	count   int
+}
+
+func (f *ndjsonFile) write(record interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if f.appending {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(f.path, flags, 0o644)
+		if err != nil {
+			return err
+		}
+		f.file, f.encoder = file, json.NewEncoder(file)
+	}
+	f.count++
+	return f.encoder.Encode(record)
+}
+
+func (f *ndjsonFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
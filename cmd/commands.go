@@ -7,16 +7,30 @@ import (
 	"dcli/models"
 	"dcli/utils"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
-	"text/tabwriter"
+	"sync"
+	"time"
 )
 
+const usage = "Expected 'create', 'read', 'update', 'edit', 'delete', 'apply', 'diff', 'import', 'export', 'list', 'count', 'search', 'relation', 'describe', 'permission', 'actions', 'execute', 'raw', 'history', 'undo', 'cp' subcommands"
+
 func main() {
+	// Parse global flags given before the subcommand
+	globalCmd := flag.NewFlagSet("dcli", flag.ExitOnError)
+	registerGlobalFlags(globalCmd)
+	debug := globalCmd.Bool("debug", false, "Log requests and debug information to stderr")
+	profile := globalCmd.String("profile", "", "Configuration profile to use (default: the top-level base_url and api_key)")
+	globalCmd.Parse(os.Args[1:])
+	args := globalCmd.Args()
+
 	// Initialize logger
-	utils.InitLogger(false)
+	utils.InitLogger(*debug)
 
 	// Load configuration
 	config, err := utils.LoadConfig("")
@@ -26,47 +40,102 @@ func main() {
 	}
 
 	// Create API client
-	client, err := api.NewClient(config.BaseURL, config.APIKey)
+	client, err := connect(config, *profile, strings.Join(args, " "))
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to create API client:", err)
 		os.Exit(1)
 	}
 
 	// Parse command-line arguments
-	if len(os.Args) < 2 {
-		fmt.Println("Expected 'create', 'read', 'update', 'delete', 'list', 'relation', 'describe', 'permission', 'actions', 'execute' subcommands")
+	if len(args) < 1 {
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "create":
-		createCommand(client, os.Args[2:])
+		createCommand(client, args[1:])
 	case "read":
-		readCommand(client, os.Args[2:])
+		readCommand(client, args[1:])
 	case "update":
-		updateCommand(client, os.Args[2:])
+		updateCommand(client, args[1:])
+	case "edit":
+		editCommand(client, args[1:])
 	case "delete":
-		deleteCommand(client, os.Args[2:])
+		deleteCommand(client, args[1:])
+	case "apply":
+		applyCommand(client, args[1:])
+	case "diff":
+		diffCommand(client, args[1:])
+	case "import":
+		importCommand(client, args[1:])
+	case "export":
+		exportCommand(client, args[1:])
 	case "list":
-		listCommand(client, os.Args[2:])
+		listCommand(client, args[1:])
+	case "count":
+		countCommand(client, args[1:])
+	case "search":
+		searchCommand(client, args[1:])
 	case "relation":
-		relationCommand(client, os.Args[2:])
+		relationCommand(client, args[1:])
 	case "describe":
-		describeCommand(client, os.Args[2:])
+		describeCommand(client, args[1:])
 	case "permission":
-		permissionCommand(client, os.Args[2:])
+		permissionCommand(client, args[1:])
 	case "actions":
-		actionsCommand(client, os.Args[2:])
+		actionsCommand(client, args[1:])
 	case "execute":
-		executeCommand(client, os.Args[2:])
+		executeCommand(client, args[1:])
+	case "raw", "api":
+		rawCommand(client, args[0], args[1:])
+	case "cp":
+		copyCommand(client, config, args[1:])
+	case "history":
+		historyCommand(client, args[1:])
+	case "undo":
+		undoCommand(client, args[1:])
 	default:
-		fmt.Println("Expected 'create', 'read', 'update', 'delete', 'list', 'relation', 'describe', 'permission', 'actions', 'execute' subcommands")
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 }
 
+// connect creates the client of a configuration profile. Under --dry-run it
+// prints requests that change data instead of sending them, and changes it
+// makes are journaled for undo under the profile, described by command.
+func connect(config *utils.Config, profileName, command string) (*api.Client, error) {
+	profile, err := config.Profile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	client, err := api.NewClient(profile.BaseURL, profile.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	client.DryRun = interceptDryRun
+	client.Recorder = openJournal(client, profileName, command)
+	return client, nil
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments and returns the positional arguments in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
 func actionsCommand(client *api.Client, args []string) {
 	actionsCmd := flag.NewFlagSet("actions", flag.ExitOnError)
+	registerGlobalFlags(actionsCmd)
 	entityType := actionsCmd.String("type", "", "Entity type to list actions for")
 	actionsCmd.Parse(args)
 
@@ -83,22 +152,32 @@ func actionsCommand(client *api.Client, args []string) {
 	}
 
 	// Display actions
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Name\tLabel\tInstanceOptional")
-	fmt.Fprintln(w, "----\t-----\t----------------")
+	view := &View{
+		Columns: columnNames("Name", "Label", "InstanceOptional"),
+		Data:    actions,
+	}
 	for _, action := range actions {
-		fmt.Fprintf(w, "%s\t%s\t%v\n", action.Name, action.Label, action.InstanceOptional)
+		view.Rows = append(view.Rows, []string{action.Name, action.Label, fmt.Sprintf("%v", action.InstanceOptional)})
 	}
-	w.Flush()
+	render(view)
 }
 
 func executeCommand(client *api.Client, args []string) {
 	executeCmd := flag.NewFlagSet("execute", flag.ExitOnError)
+	registerGlobalFlags(executeCmd)
 	actionName := executeCmd.String("name", "", "Name of the action to execute")
 	entityType := executeCmd.String("type", "", "Entity type of the action")
 	inputValues := executeCmd.String("inputs", "", "Comma-separated key=value pairs of input values")
+	noValidate := executeCmd.Bool("no-validate", false, "Send the inputs without checking them against the action's fields")
+	id := executeCmd.String("id", "", "ID of the record to run the action on, for actions on an instance")
+	by := registerByFlag(executeCmd, true)
 	executeCmd.Parse(args)
 
+	if err := resolveID(client, *entityType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
 	if *entityType == "" || *actionName == "" {
 		fmt.Println("Both entity type and action name are required.")
 		executeCmd.Usage()
@@ -113,6 +192,10 @@ func executeCommand(client *api.Client, args []string) {
 	}
 
 	inputs := make(map[string]interface{})
+	if *id != "" {
+		// daptin takes the instance an action runs on as the <type>_id input
+		inputs[*entityType+"_id"] = *id
+	}
 
 	// Parse inputValues if provided
 	if *inputValues != "" {
@@ -129,34 +212,65 @@ func executeCommand(client *api.Client, args []string) {
 	// Collect missing input values interactively
 	for _, field := range action.InFields {
 		if _, ok := inputs[field.Name]; !ok {
-			fmt.Printf("Enter value for %s (%s): ", field.Name, field.ColumnType)
+			fmt.Fprintf(os.Stderr, "Enter value for %s (%s): ", field.Name, field.ColumnType)
 			var value string
 			fmt.Scanln(&value)
 			inputs[field.Name] = value
 		}
 	}
 
+	if !*noValidate {
+		if err := action.Validate(inputs); err != nil {
+			utils.ErrorLogger.Println("Invalid inputs:", err)
+			os.Exit(1)
+		}
+	}
+
 	// Execute the action
 	result, err := client.ExecuteAction(*entityType, *actionName, inputs)
+	if isDryRun(err) {
+		return
+	}
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to execute action:", err)
 		os.Exit(1)
 	}
 
 	// Display the result
-	fmt.Println("Action executed successfully. Result:")
-	resultJSON, _ := json.MarshalIndent(result, "", "  ")
-	fmt.Println(string(resultJSON))
+	render(actionResultView(result))
+}
+
+// actionResultView shows the responses of an action, one row per response.
+func actionResultView(result []map[string]interface{}) *View {
+	view := &View{
+		Title:   "Action executed successfully. Result",
+		Columns: columnNames("Response Type", "Attributes"),
+		Data:    result,
+	}
+	for _, response := range result {
+		responseType := fmt.Sprintf("%v", response["ResponseType"])
+		attributes, _ := json.Marshal(response["Attributes"])
+		view.Rows = append(view.Rows, []string{responseType, string(attributes)})
+	}
+	return view
 }
 
 func permissionCommand(client *api.Client, args []string) {
 	permCmd := flag.NewFlagSet("permission", flag.ExitOnError)
+	registerGlobalFlags(permCmd)
 	entityType := permCmd.String("type", "", "Entity type")
 	objectID := permCmd.String("id", "", "Object ID (reference_id)")
+	by := registerByFlag(permCmd, true)
 	action := permCmd.String("action", "view", "Action to perform: view, set, add, remove")
 	permissions := permCmd.String("permissions", "", "Comma-separated list of permissions to set/add/remove")
+	ifVersion := registerVersionFlag(permCmd)
 	permCmd.Parse(args)
 
+	if err := resolveID(client, *entityType, objectID, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
 	if *entityType == "" || *objectID == "" {
 		permCmd.Usage()
 		os.Exit(1)
@@ -166,11 +280,11 @@ func permissionCommand(client *api.Client, args []string) {
 	case "view":
 		viewPermissions(client, *entityType, *objectID)
 	case "set":
-		setPermissions(client, *entityType, *objectID, *permissions)
+		setPermissions(client, *entityType, *objectID, *permissions, *ifVersion)
 	case "add":
-		addPermissions(client, *entityType, *objectID, *permissions)
+		addPermissions(client, *entityType, *objectID, *permissions, *ifVersion)
 	case "remove":
-		removePermissions(client, *entityType, *objectID, *permissions)
+		removePermissions(client, *entityType, *objectID, *permissions, *ifVersion)
 	default:
 		fmt.Println("Invalid action. Expected 'view', 'set', 'add', or 'remove'.")
 		os.Exit(1)
@@ -183,31 +297,56 @@ func viewPermissions(client *api.Client, entityType, objectID string) {
 		os.Exit(1)
 	}
 
+	view := permissionView(entityType, objectID, perm, "")
+	view.Title = fmt.Sprintf("Permissions for %s (%s)", entityType, objectID)
+	render(view)
+}
+
+// permissionView lists the permission bits of an object. A non-empty message
+// replaces the list in table formats.
+func permissionView(entityType, objectID string, perm api.AuthPermission, message string) *View {
 	permNames := api.AuthPermissionToStrings(perm)
-	fmt.Printf("Permissions for %s (%s):\n", entityType, objectID)
-	for _, name := range permNames {
-		fmt.Println(" -", name)
+	sort.Strings(permNames)
+
+	view := &View{
+		Message: message,
+		Data: map[string]interface{}{
+			"type":        entityType,
+			"id":          objectID,
+			"permission":  int64(perm),
+			"permissions": permNames,
+		},
+	}
+	if message == "" {
+		view.Columns = columnNames("Permission")
+		for _, name := range permNames {
+			view.Rows = append(view.Rows, []string{name})
+		}
 	}
+	return view
 }
 
-func setPermissions(client *api.Client, entityType, objectID, permissions string) {
+func setPermissions(client *api.Client, entityType, objectID, permissions string, ifVersion int64) {
 	perm, err := api.StringsToAuthPermission(strings.Split(permissions, ","))
 	if err != nil {
 		utils.ErrorLogger.Println("Invalid permissions:", err)
 		os.Exit(1)
 	}
 
-	err = client.SetPermissions(entityType, objectID, perm)
+	err = client.SetPermissionsIfVersion(entityType, objectID, perm, ifVersion)
+	if isDryRun(err) {
+		return
+	}
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to set permissions:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Permissions set successfully.")
+	render(permissionView(entityType, objectID, perm, "Permissions set successfully."))
 }
 
-func addPermissions(client *api.Client, entityType, objectID, permissions string) {
-	existingPerm, err := client.GetPermissions(entityType, objectID)
+func addPermissions(client *api.Client, entityType, objectID, permissions string, ifVersion int64) {
+	existingPerm, version, err := readPermissionsAt(client, entityType, objectID, ifVersion)
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to get existing permissions:", err)
 		os.Exit(1)
@@ -221,17 +360,20 @@ func addPermissions(client *api.Client, entityType, objectID, permissions string
 
 	combinedPerm := existingPerm | newPerm
 
-	err = client.SetPermissions(entityType, objectID, combinedPerm)
+	err = client.SetPermissionsIfVersion(entityType, objectID, combinedPerm, version)
+	if isDryRun(err) {
+		return
+	}
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to add permissions:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Permissions added successfully.")
+	render(permissionView(entityType, objectID, combinedPerm, "Permissions added successfully."))
 }
 
-func removePermissions(client *api.Client, entityType, objectID, permissions string) {
-	existingPerm, err := client.GetPermissions(entityType, objectID)
+func removePermissions(client *api.Client, entityType, objectID, permissions string, ifVersion int64) {
+	existingPerm, version, err := readPermissionsAt(client, entityType, objectID, ifVersion)
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to get existing permissions:", err)
 		os.Exit(1)
@@ -245,17 +387,42 @@ func removePermissions(client *api.Client, entityType, objectID, permissions str
 
 	updatedPerm := existingPerm &^ remPerm
 
-	err = client.SetPermissions(entityType, objectID, updatedPerm)
+	err = client.SetPermissionsIfVersion(entityType, objectID, updatedPerm, version)
+	if isDryRun(err) {
+		return
+	}
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to remove permissions:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Permissions removed successfully.")
+	render(permissionView(entityType, objectID, updatedPerm, "Permissions removed successfully."))
+}
+
+// readPermissionsAt reads the permission of a resource for a
+// read-modify-write, with the version the write must still find. When
+// ifVersion is set, the resource must already be at that version.
+func readPermissionsAt(client *api.Client, entityType, objectID string, ifVersion int64) (api.AuthPermission, int64, error) {
+	perm, version, err := client.GetVersionedPermissions(entityType, objectID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if ifVersion >= 0 && version != ifVersion {
+		return 0, 0, &api.ConflictError{Type: entityType, ID: objectID, Expected: ifVersion, Actual: version}
+	}
+	return perm, version, nil
+}
+
+// registerVersionFlag adds --if-version, which makes a write fail with a
+// conflict when the resource is no longer at the given version. It is -1
+// when not given.
+func registerVersionFlag(fs *flag.FlagSet) *int64 {
+	return fs.Int64("if-version", -1, "Only write if the resource is still at this version. It is checked with a read right before the write, which narrows the window for lost updates but does not close it")
 }
 
 func describeCommand(client *api.Client, args []string) {
 	describeCmd := flag.NewFlagSet("describe", flag.ExitOnError)
+	registerGlobalFlags(describeCmd)
 	entityName := describeCmd.String("type", "", "Entity type to describe")
 	describeCmd.Parse(args)
 
@@ -274,21 +441,12 @@ func describeCommand(client *api.Client, args []string) {
 }
 
 func displayEntityModel(entityName string, model *api.TableInfo) {
-	standardColumns := map[string]struct{}{
-		"id":           {},
-		"version":      {},
-		"created_at":   {},
-		"updated_at":   {},
-		"reference_id": {},
-		"permission":   {},
-	}
-
 	columns := []api.ColumnInfo{}
 	relations := []api.ColumnInfo{}
 
 	for name, col := range model.ColumnModel {
 		col.Name = name // Assign the name from the map key
-		if _, ok := standardColumns[col.Name]; ok {
+		if _, ok := api.StandardColumns[col.Name]; ok {
 			continue // Skip standard columns
 		}
 		if col.JsonApi != "" {
@@ -297,212 +455,575 @@ func displayEntityModel(entityName string, model *api.TableInfo) {
 			columns = append(columns, col)
 		}
 	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	sort.Slice(relations, func(i, j int) bool { return relations[i].Name < relations[j].Name })
 
-	// Initialize tabwriter with wider padding for better formatting
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-
-	fmt.Fprintf(w, "Entity: %s\n\n", entityName)
+	view := &View{
+		Message: fmt.Sprintf("Entity: %s", entityName),
+		Data:    model,
+	}
 
 	// Display Columns
 	if len(columns) > 0 {
-		fmt.Fprintln(w, "Columns:")
-		fmt.Fprintln(w, "Name\tType\tData Type\tDescription")
-		fmt.Fprintln(w, "----\t----\t---------\t-----------")
-
+		section := &View{
+			Title:   "Columns",
+			Columns: columnNames("Name", "Type", "Data Type", "Description"),
+		}
 		for _, col := range columns {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", col.Name, col.ColumnType, col.DataType, col.ColumnDescription)
+			section.Rows = append(section.Rows, []string{col.Name, col.ColumnType, col.DataType, col.ColumnDescription})
 		}
-
-		w.Flush()
+		view.Sections = append(view.Sections, section)
 	}
 
 	// Display Relations
 	if len(relations) > 0 {
-		fmt.Println("\nRelations:")
-		fmt.Fprintln(w, "Name\tRelation Type\tRelated Entity")
-		fmt.Fprintln(w, "----\t-------------\t--------------")
-
+		section := &View{
+			Title:   "Relations",
+			Columns: columnNames("Name", "Relation Type", "Related Entity"),
+		}
 		for _, rel := range relations {
-			fmt.Fprintf(w, "%s\t%s\t%s\n", rel.Name, rel.JsonApi, rel.Type)
+			section.Rows = append(section.Rows, []string{rel.Name, rel.JsonApi, rel.Type})
 		}
-
-		w.Flush()
+		view.Sections = append(view.Sections, section)
 	}
 
 	// Display Actions
 	if len(model.Actions) > 0 {
-		fmt.Println("\nActions:")
-		fmt.Fprintln(w, "Name\tLabel\tInput Fields")
-		fmt.Fprintln(w, "----\t-----\t------------")
-
-		// Collect action lines
-		actionLines := []string{}
+		section := &View{
+			Title:   "Actions",
+			Columns: columnNames("Name", "Label", "Input Fields"),
+		}
 		for _, action := range model.Actions {
 			// Collect input fields in a compact format
 			inputFields := []string{}
 			for _, inField := range action.InFields {
 				inputFields = append(inputFields, fmt.Sprintf("%s(%s)", inField.Name, inField.ColumnType))
 			}
-			inputFieldsStr := strings.Join(inputFields, ", ")
-
-			// Prepare the action line
-			actionLine := fmt.Sprintf("%s\t%s\t%s", action.Name, action.Label, inputFieldsStr)
-			actionLines = append(actionLines, actionLine)
+			section.Rows = append(section.Rows, []string{action.Name, action.Label, strings.Join(inputFields, ", ")})
 		}
-
-		// Display actions in two columns
-		for i := 0; i < len(actionLines); i += 2 {
-			line := actionLines[i]
-			if i+1 < len(actionLines) {
-				line += "\t\t" + actionLines[i+1]
-			}
-			fmt.Fprintln(w, line)
-		}
-
-		w.Flush()
+		view.Sections = append(view.Sections, section)
 	}
+
+	render(view)
 }
 
 func createCommand(client *api.Client, args []string) {
 	createCmd := flag.NewFlagSet("create", flag.ExitOnError)
+	registerGlobalFlags(createCmd)
 	resourceType := createCmd.String("type", "", "Resource type")
-	attributes := createCmd.String("attributes", "", "Resource attributes in JSON format")
+	input := registerAttributeFlags(createCmd)
+	interactive := createCmd.Bool("interactive", false, "Prompt for every column of the entity")
 	createCmd.Parse(args)
 
-	if *resourceType == "" || *attributes == "" {
+	if *interactive {
+		if *resourceType == "" || !input.empty() {
+			fmt.Println("-interactive needs -type and no other attribute flags.")
+			createCmd.Usage()
+			os.Exit(1)
+		}
+	} else if input.empty() {
 		createCmd.Usage()
 		os.Exit(1)
 	}
 
-	var attrs map[string]interface{}
-	err := json.Unmarshal([]byte(*attributes), &attrs)
+	var resources []*models.Resource
+	var err error
+	if *interactive {
+		var resource *models.Resource
+		resource, _, err = promptResource(client, *resourceType, "")
+		resources = []*models.Resource{resource}
+	} else {
+		resources, err = input.resources(client, *resourceType, "")
+	}
 	if err != nil {
-		utils.ErrorLogger.Println("Invalid attributes JSON:", err)
+		utils.ErrorLogger.Println("Invalid input:", err)
+		os.Exit(1)
+	}
+	if err := input.validate(client, resources, true); err != nil {
+		utils.ErrorLogger.Println("Invalid input:", err)
 		os.Exit(1)
 	}
 
-	resource := &models.Resource{
-		Type:       *resourceType,
-		Attributes: attrs,
+	if len(resources) == 1 {
+		createdResource, err := createLinked(client, resources[0])
+		if isDryRun(err) {
+			return
+		}
+		if err != nil {
+			utils.ErrorLogger.Println("Failed to create resource:", err)
+			os.Exit(1)
+		}
+
+		// Display the created resource
+		render(singleResourceView(createdResource, loadModel(client, createdResource.Type)))
+		return
 	}
 
-	createdResource, err := client.Create(resource)
-	if err != nil {
-		utils.ErrorLogger.Println("Failed to create resource:", err)
+	results := make([]batchResult, len(resources))
+	for i, resource := range resources {
+		results[i] = batchResult{Item: i + 1, Type: resource.Type}
+		created, err := createLinked(client, resource)
+		if created != nil {
+			results[i].ID = created.ID
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	if globalOptions.dryRun {
+		return
+	}
+	render(batchView(results, "created"))
+	if batchFailed(results) {
 		os.Exit(1)
 	}
-
-	// Display the created resource
-	displaySingleResource(createdResource)
 }
 func readCommand(client *api.Client, args []string) {
 	readCmd := flag.NewFlagSet("read", flag.ExitOnError)
+	registerGlobalFlags(readCmd)
 	resourceType := readCmd.String("type", "", "Resource type")
 	id := readCmd.String("id", "", "Resource ID")
+	by := registerByFlag(readCmd, true)
+	include := readCmd.String("include", "", "Comma-separated relations to include")
+	columns := readCmd.String("columns", "", "Comma-separated fields to show, e.g. 'name,email,author.name'")
 	readCmd.Parse(args)
 
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
 	if *resourceType == "" || *id == "" {
 		readCmd.Usage()
 		os.Exit(1)
 	}
 
-	resource, err := client.Read(*resourceType, *id)
+	doc, err := client.ReadDocument(*resourceType, *id, *include)
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to read resource:", err)
 		os.Exit(1)
 	}
 
-	displaySingleResource(resource)
-}
-
-func displaySingleResource(resource *models.Resource) {
-	// Initialize tabwriter
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-
-	// Print header
-	fmt.Fprintln(w, "Field\tValue")
-	fmt.Fprintln(w, "-----\t-----")
-
-	// Print ID and Type
-	fmt.Fprintf(w, "ID\t%s\n", resource.ID)
-	fmt.Fprintf(w, "Type\t%s\n", resource.Type)
+	resources, err := doc.Resolve()
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to parse resource data:", err)
+		os.Exit(1)
+	}
+	if len(resources) == 0 {
+		utils.ErrorLogger.Printf("Failed to read resource: %s %s not found", *resourceType, *id)
+		os.Exit(1)
+	}
 
-	// Print attributes
-	for key, val := range resource.Attributes {
-		strVal := fmt.Sprintf("%v", val)
-		if len(strVal) > 100 {
-			strVal = strVal[:100] + "..." // Truncate long strings
-		}
-		fmt.Fprintf(w, "%s\t%s\n", key, strVal)
+	view := singleResourceView(resources[0], loadModel(client, *resourceType))
+	if err := view.keepColumns(parseColumnList(*columns)); err != nil {
+		utils.ErrorLogger.Println("Invalid columns:", err)
+		os.Exit(1)
 	}
+	render(view)
+}
 
-	// Flush the writer
-	w.Flush()
+// singleResourceView shows one resource as field/value pairs.
+func singleResourceView(resource *models.Resource, model *api.TableInfo) *View {
+	view := resourcesView([]*models.Resource{resource}, model)
+	view.Vertical = true
+	view.Data = view.Items[0]
+	return view
 }
 
 func updateCommand(client *api.Client, args []string) {
 	updateCmd := flag.NewFlagSet("update", flag.ExitOnError)
+	registerGlobalFlags(updateCmd)
 	resourceType := updateCmd.String("type", "", "Resource type")
-	id := updateCmd.String("id", "", "Resource ID")
-	attributes := updateCmd.String("attributes", "", "Resource attributes in JSON format")
+	id := updateCmd.String("id", "", "Resource ID; optional when the input documents carry IDs")
+	by := registerByFlag(updateCmd, false)
+	input := registerAttributeFlags(updateCmd)
+	ifVersion := registerVersionFlag(updateCmd)
+	bulk := registerBulkFlags(updateCmd, "update")
+	logPath := updateCmd.String("log", "", "With -where or --ids-from, file to write every record's result and previous values to (default: <type>-update-<time>.ndjson)")
+	interactive := updateCmd.Bool("interactive", false, "Prompt for every column of the record, showing its current values")
 	updateCmd.Parse(args)
 
-	if *resourceType == "" || *id == "" || *attributes == "" {
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
+	if *interactive {
+		if *resourceType == "" || *id == "" || !input.empty() || bulk.enabled() {
+			fmt.Println("-interactive needs -type and -id or -by, and no other attribute flags.")
+			updateCmd.Usage()
+			os.Exit(1)
+		}
+	} else if input.empty() {
 		updateCmd.Usage()
 		os.Exit(1)
 	}
+	if bulk.enabled() {
+		if *resourceType == "" || *id != "" || *ifVersion >= 0 {
+			utils.ErrorLogger.Println("Invalid input: -where and --ids-from need -type, and cannot be combined with -id or --if-version")
+			os.Exit(1)
+		}
+		updateMatching(client, *resourceType, input, bulk, *logPath)
+		return
+	}
 
-	var attrs map[string]interface{}
-	err := json.Unmarshal([]byte(*attributes), &attrs)
+	var resources []*models.Resource
+	var err error
+	expected := *ifVersion
+	if *interactive {
+		// The answers are based on the values shown, so the record must not
+		// have changed while they were given
+		var resource *models.Resource
+		var version int64
+		resource, version, err = promptResource(client, *resourceType, *id)
+		if err == nil && len(resource.Attributes) == 0 && len(resource.Relationships) == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing changed.")
+			return
+		}
+		if expected < 0 {
+			expected = version
+		}
+		resources = []*models.Resource{resource}
+	} else {
+		resources, err = input.resources(client, *resourceType, *id)
+	}
 	if err != nil {
-		utils.ErrorLogger.Println("Invalid attributes JSON:", err)
+		utils.ErrorLogger.Println("Invalid input:", err)
+		os.Exit(1)
+	}
+	for i, resource := range resources {
+		if resource.ID == "" {
+			utils.ErrorLogger.Printf("Invalid input: item %d has no id; pass -id", i+1)
+			os.Exit(1)
+		}
+	}
+	if err := input.validate(client, resources, false); err != nil {
+		utils.ErrorLogger.Println("Invalid input:", err)
 		os.Exit(1)
 	}
 
-	resource := &models.Resource{
-		Type:       *resourceType,
-		ID:         *id,
-		Attributes: attrs,
+	if *ifVersion >= 0 && len(resources) != 1 {
+		utils.ErrorLogger.Println("Invalid input: --if-version applies to a single resource")
+		os.Exit(1)
+	}
+
+	if len(resources) == 1 {
+		updatedResource, err := updateLinkedIfVersion(client, resources[0], expected)
+		if isDryRun(err) {
+			return
+		}
+		var conflict *api.ConflictError
+		if errors.As(err, &conflict) && *interactive {
+			utils.ErrorLogger.Println("Failed to update resource:", err)
+			utils.ErrorLogger.Println("Nothing was changed; run the update again to see the current values")
+			os.Exit(1)
+		}
+		if err != nil {
+			utils.ErrorLogger.Println("Failed to update resource:", err)
+			os.Exit(1)
+		}
+		render(singleResourceView(updatedResource, loadModel(client, updatedResource.Type)))
+		return
+	}
+
+	results := make([]batchResult, len(resources))
+	for i, resource := range resources {
+		results[i] = batchResult{Item: i + 1, Type: resource.Type, ID: resource.ID}
+		if _, err := updateLinked(client, resource); err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	if globalOptions.dryRun {
+		return
 	}
+	render(batchView(results, "updated"))
+	if batchFailed(results) {
+		os.Exit(1)
+	}
+}
+
+// updateLogEntry is the line of the result log of a bulk update for one
+// record. It is a resource object holding the values and linkage the record
+// had before, so that `update -f` with the log reverts the changes.
+type updateLogEntry struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]interface{}         `json:"attributes"`
+	Relationships map[string]models.Relationship `json:"relationships,omitempty"`
+	Meta          updateLogMeta                  `json:"meta"`
+}
+
+type updateLogMeta struct {
+	Result string                         `json:"result"` // updated or failed
+	Error  string                         `json:"error,omitempty"`
+	Set    map[string]interface{}         `json:"set"`
+	Link   map[string]models.Relationship `json:"link,omitempty"`
+}
 
-	updatedResource, err := client.Update(resource)
+// updateMatching applies the same attributes to every record matching the
+// filters, or listed in the --ids-from file, after showing how many and
+// asking for confirmation. The result of every record is logged with its
+// previous values.
+func updateMatching(client *api.Client, resourceType string, input *attributeFlags, bulk *bulkFlags, logPath string) {
+	changes, err := input.resources(client, resourceType, "")
+	if err == nil && (len(changes) != 1 || changes[0].ID != "") {
+		err = fmt.Errorf("-where and --ids-from take one set of attributes without an id, applied to every record")
+	}
+	if err == nil {
+		err = input.validate(client, changes, false)
+	}
 	if err != nil {
-		utils.ErrorLogger.Println("Failed to update resource:", err)
+		utils.ErrorLogger.Println("Invalid input:", err)
 		os.Exit(1)
 	}
-	displaySingleResource(updatedResource)
+	change := changes[0]
 
+	targets, err := bulk.targets(client, resourceType)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to find the records to update:", err)
+		os.Exit(1)
+	}
+	if len(targets) == 0 {
+		render(&View{Message: fmt.Sprintf("No %s records match.", resourceType), Data: []batchResult{}})
+		return
+	}
+
+	var assignments []string
+	for _, column := range sortedKeys(change.Attributes) {
+		assignments = append(assignments, column+"="+plainValue(change.Attributes[column]))
+	}
+	for name := range change.Relationships {
+		assignments = append(assignments, name+" (relationship)")
+	}
+	previewBulk(client, fmt.Sprintf("%d %s records will be updated with %s", len(targets), resourceType, strings.Join(assignments, ", ")), targets)
+	if !bulk.confirm(fmt.Sprintf("Update %d records?", len(targets))) {
+		fmt.Fprintln(os.Stderr, "Update cancelled.")
+		os.Exit(1)
+	}
+
+	if logPath == "" && !globalOptions.dryRun {
+		logPath = fmt.Sprintf("%s-update-%s.ndjson", resourceType, time.Now().Format("20060102-150405"))
+	}
+	log := &ndjsonFile{path: logPath}
+	var logErr error
+	var logOnce sync.Once
+	relations := make([]string, 0, len(change.Relationships))
+	for name := range change.Relationships {
+		relations = append(relations, name)
+	}
+	sort.Strings(relations)
+	results := bulk.run(targets, func(res *models.Resource) error {
+		entry := updateLogEntry{
+			Type:       res.Type,
+			ID:         res.ID,
+			Attributes: map[string]interface{}{},
+			Meta:       updateLogMeta{Set: change.Attributes, Link: change.Relationships},
+		}
+		before := res
+		var err error
+		if res.Attributes == nil || len(relations) > 0 {
+			before, err = readLinked(client, res.Type, res.ID, relations)
+		}
+		if err == nil {
+			for column := range change.Attributes {
+				entry.Attributes[column] = before.Attributes[column]
+			}
+			entry.Relationships, err = previousLinkage(before, change.Relationships)
+		}
+		if err == nil {
+			// The previous values are only right if the record is still at
+			// the version they were read at
+			version, ok := api.ResourceVersion(before)
+			if !ok {
+				version = -1
+			}
+			_, err = updateLinkedIfVersion(client, &models.Resource{Type: res.Type, ID: res.ID, Attributes: change.Attributes, Relationships: change.Relationships}, version)
+		}
+
+		if isDryRun(err) {
+			return err
+		}
+		entry.Meta.Result = "updated"
+		if err != nil {
+			entry.Meta.Result, entry.Meta.Error = "failed", err.Error()
+		}
+		if werr := log.write(entry); werr != nil {
+			logOnce.Do(func() { logErr = werr })
+		}
+		return err
+	})
+	if err := log.close(); err != nil && logErr == nil {
+		logErr = err
+	}
+	if globalOptions.dryRun {
+		fmt.Fprintln(os.Stderr, "Dry run, nothing updated.")
+		return
+	}
+
+	render(batchView(results, "updated"))
+	if logErr != nil {
+		utils.ErrorLogger.Println("Failed to write the result log:", logErr)
+	} else {
+		fmt.Fprintf(os.Stderr, "Results and previous values were written to %s; 'dcli update -f %s' reverts the changes.\n", logPath, logPath)
+	}
+	if batchFailed(results) {
+		os.Exit(1)
+	}
+}
+
+// previousLinkage returns the linkage a record had for the relationships an
+// update changes, read with those relations included. An update is only made
+// when it can be reverted, so it fails when the server did not return the
+// linkage of a relation: logging it as empty would make a revert unlink it.
+func previousLinkage(before *models.Resource, changed map[string]models.Relationship) (map[string]models.Relationship, error) {
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	linkage := make(map[string]models.Relationship, len(changed))
+	for name, rel := range changed {
+		current, ok := before.Relationships[name]
+		if !ok || rel.IsToMany() && !current.IsToMany() {
+			return nil, fmt.Errorf("the server did not return the linkage of %s, so the change could not be reverted; nothing was changed", name)
+		}
+		linkage[name] = linkageOnly(current)
+	}
+	return linkage, nil
 }
 
 func deleteCommand(client *api.Client, args []string) {
 	deleteCmd := flag.NewFlagSet("delete", flag.ExitOnError)
+	registerGlobalFlags(deleteCmd)
 	resourceType := deleteCmd.String("type", "", "Resource type")
 	id := deleteCmd.String("id", "", "Resource ID")
+	by := registerByFlag(deleteCmd, false)
+	ifVersion := registerVersionFlag(deleteCmd)
+	bulk := registerBulkFlags(deleteCmd, "delete")
 	deleteCmd.Parse(args)
 
-	if *resourceType == "" || *id == "" {
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
+	if *resourceType == "" || (*id == "") == !bulk.enabled() {
 		deleteCmd.Usage()
 		os.Exit(1)
 	}
+	if bulk.enabled() {
+		if *ifVersion >= 0 {
+			utils.ErrorLogger.Println("Invalid input: --if-version applies to a single resource")
+			os.Exit(1)
+		}
+		deleteMatching(client, *resourceType, bulk)
+		return
+	}
 
-	err := client.Delete(*resourceType, *id)
+	var err error
+	if *ifVersion >= 0 {
+		err = client.CheckVersion(*resourceType, *id, *ifVersion)
+	}
+	if err == nil {
+		err = client.Delete(*resourceType, *id)
+	}
+	if isDryRun(err) {
+		return
+	}
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to delete resource:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Resource deleted successfully.")
+	render(&View{
+		Message: "Resource deleted successfully.",
+		Data:    map[string]interface{}{"type": *resourceType, "id": *id, "deleted": true},
+	})
+}
+
+// deleteMatching deletes every record matching the filters, or listed in
+// the --ids-from file, after showing how many and asking for confirmation.
+func deleteMatching(client *api.Client, resourceType string, bulk *bulkFlags) {
+	targets, err := bulk.targets(client, resourceType)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to find the records to delete:", err)
+		os.Exit(1)
+	}
+	if len(targets) == 0 {
+		render(&View{Message: fmt.Sprintf("No %s records match.", resourceType), Data: []batchResult{}})
+		return
+	}
+
+	previewBulk(client, fmt.Sprintf("%d %s records will be deleted", len(targets), resourceType), targets)
+	if !bulk.confirm(fmt.Sprintf("Delete %d records?", len(targets))) {
+		fmt.Fprintln(os.Stderr, "Delete cancelled.")
+		os.Exit(1)
+	}
+
+	results := bulk.run(targets, func(res *models.Resource) error {
+		return client.Delete(res.Type, res.ID)
+	})
+	if globalOptions.dryRun {
+		fmt.Fprintln(os.Stderr, "Dry run, nothing deleted.")
+		return
+	}
+	render(batchView(results, "deleted"))
+	if batchFailed(results) {
+		os.Exit(1)
+	}
+}
+
+// filterFlags holds the filtering options shared by commands that query lists of resources.
+type filterFlags struct {
+	filters *string
+	where   *string
+}
+
+func registerFilterFlags(fs *flag.FlagSet) *filterFlags {
+	return &filterFlags{
+		filters: fs.String("filter", "", "Filters in key1:value1,key2:value2 format"),
+		where:   fs.String("where", "", "Conditions in column=value,column>value format (operators: = != > >= < <= ~)"),
+	}
+}
+
+// empty reports whether no filter or condition was given.
+func (f *filterFlags) empty() bool {
+	return *f.filters == "" && *f.where == ""
+}
+
+// apply adds the parsed filters and conditions to the list options.
+func (f *filterFlags) apply(options *api.ListOptions) error {
+	if *f.filters != "" {
+		if options.Filter == nil {
+			options.Filter = make(map[string]string)
+		}
+		filterPairs := strings.Split(*f.filters, ",")
+		for _, pair := range filterPairs {
+			kv := strings.SplitN(pair, ":", 2)
+			if len(kv) == 2 {
+				options.Filter[kv[0]] = kv[1]
+			}
+		}
+	}
+	if *f.where != "" {
+		conditions, err := api.ParseWhere(*f.where)
+		if err != nil {
+			return err
+		}
+		options.Query = append(options.Query, conditions...)
+	}
+	return nil
 }
 
 func listCommand(client *api.Client, args []string) {
 	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
+	registerGlobalFlags(listCmd)
 	resourceType := listCmd.String("type", "", "Resource type")
 	pageNumber := listCmd.String("page[number]", "", "Page number")
 	pageSize := listCmd.String("page[size]", "", "Page size")
-	filters := listCmd.String("filter", "", "Filters in key1:value1,key2:value2 format")
+	filters := registerFilterFlags(listCmd)
 	sort := listCmd.String("sort", "", "Sort fields, e.g., 'name,-created_at'")
-	include := listCmd.String("include", "", "Related resources to include")
+	include := listCmd.String("include", "", "Comma-separated relations to include")
 	fields := listCmd.String("fields", "", "Fields to return in key1:field1,field2;key2:field3 format")
+	columns := listCmd.String("columns", "", "Comma-separated columns to show, e.g. 'ID,name,email,author.name'")
 	listCmd.Parse(args)
 
 	if *resourceType == "" {
@@ -522,14 +1043,9 @@ func listCommand(client *api.Client, args []string) {
 	if *pageSize != "" {
 		options.Page["size"] = *pageSize
 	}
-	if *filters != "" {
-		filterPairs := strings.Split(*filters, ",")
-		for _, pair := range filterPairs {
-			kv := strings.SplitN(pair, ":", 2)
-			if len(kv) == 2 {
-				options.Filter[kv[0]] = kv[1]
-			}
-		}
+	if err := filters.apply(options); err != nil {
+		utils.ErrorLogger.Println("Invalid filter:", err)
+		os.Exit(1)
 	}
 	if *sort != "" {
 		options.Sort = *sort
@@ -554,81 +1070,182 @@ func listCommand(client *api.Client, args []string) {
 	}
 
 	// Process and display the data
-	resources, err := parseResourceList(doc.Data)
+	resources, err := doc.Resolve()
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to parse resource data:", err)
 		os.Exit(1)
 	}
 
-	displayResourceTable(resources)
+	view := resourcesView(resources, loadModel(client, *resourceType))
+	if err := view.keepColumns(parseColumnList(*columns)); err != nil {
+		utils.ErrorLogger.Println("Invalid columns:", err)
+		os.Exit(1)
+	}
+	view.Data = documentData(doc, view.Items)
+	view.Notes = paginationNotes(doc, len(resources))
+	render(view)
 }
 
-func parseResourceList(data interface{}) ([]*models.Resource, error) {
-	dataBytes, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
+func countCommand(client *api.Client, args []string) {
+	countCmd := flag.NewFlagSet("count", flag.ExitOnError)
+	registerGlobalFlags(countCmd)
+	resourceType := countCmd.String("type", "", "Resource type")
+	filters := registerFilterFlags(countCmd)
+	countCmd.Parse(args)
+
+	if *resourceType == "" {
+		countCmd.Usage()
+		os.Exit(1)
 	}
-	var resources []*models.Resource
-	err = json.Unmarshal(dataBytes, &resources)
-	if err != nil {
-		return nil, err
+
+	options := &api.ListOptions{}
+	if err := filters.apply(options); err != nil {
+		utils.ErrorLogger.Println("Invalid filter:", err)
+		os.Exit(1)
 	}
-	return resources, nil
-}
 
-func displayResourceTable(resources []*models.Resource) {
-	// Get the list of attribute keys
-	attributeKeys := collectAttributeKeys(resources)
+	count, err := client.Count(*resourceType, options)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to count resources:", err)
+		os.Exit(1)
+	}
 
-	// Initialize tabwriter
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	render(&View{
+		Message: strconv.Itoa(count),
+		Data:    map[string]int{"count": count},
+	})
+}
 
-	// Print table header
-	header := append([]string{"ID", "Type"}, attributeKeys...)
-	fmt.Fprintln(w, strings.Join(header, "\t"))
+// resourcesView shows resources one per row, with a column per attribute and
+// per attribute of the included related resources. Attributes are laid out
+// and formatted according to the entity model when one is given.
+func resourcesView(resources []*models.Resource, model *api.TableInfo) *View {
+	// Get the list of attribute columns
+	attributes := attributeColumns(resources, model)
+	relatedKeys := relatedColumns(resources)
 
-	// Print separator
-	separator := make([]string, len(header))
-	for i := range separator {
-		separator[i] = strings.Repeat("-", 10)
+	view := &View{
+		Columns: columnNames("ID", "Type"),
+		Items:   make([]interface{}, 0, len(resources)),
+		Values:  make([]interface{}, 0, len(resources)),
 	}
-	fmt.Fprintln(w, strings.Join(separator, "\t"))
+	view.Columns = append(view.Columns, attributes...)
+	view.Columns = append(view.Columns, columnNames(relatedKeys...)...)
 
 	// Iterate over resources
 	for _, res := range resources {
 		row := []string{res.ID, res.Type}
-		for _, key := range attributeKeys {
-			value := ""
-			if val, ok := res.Attributes[key]; ok {
-				strVal := fmt.Sprintf("%v", val)
-				if len(strVal) > 100 {
-					strVal = strVal[:100] + "..." // Truncate long strings
-				}
-				value = strVal
+		for _, col := range attributes {
+			row = append(row, formatCell(res.Attributes[col.Name], columnInfo(model, col.Name)))
+		}
+		for _, key := range relatedKeys {
+			row = append(row, relatedValue(res, key))
+		}
+		view.Rows = append(view.Rows, row)
+		view.Items = append(view.Items, resourceData(res))
+		view.Values = append(view.Values, res)
+	}
+	return view
+}
+
+// resourceData returns the structured form of a resource, with its included
+// related resources nested when there are any.
+func resourceData(res *models.Resource) interface{} {
+	for _, rel := range res.Relationships {
+		if len(rel.Resources) > 0 {
+			return res.Embedded()
+		}
+	}
+	return res
+}
+
+// documentData returns the structured form of a list response, nesting the
+// included resources into the primary resources when there are any.
+func documentData(doc *models.Document, items []interface{}) interface{} {
+	if len(doc.Included) == 0 {
+		return doc
+	}
+	return map[string]interface{}{
+		"data":  items,
+		"meta":  doc.Meta,
+		"links": doc.Links,
+	}
+}
+
+// paginationNotes describes the position of a page within the full result
+// set, followed by the page links and any other metadata of the list response.
+func paginationNotes(doc *models.Document, shown int) []string {
+	p := api.PaginationOf(doc)
+
+	var notes []string
+	switch {
+	case shown == 0 && p.HasTotal:
+		notes = append(notes, fmt.Sprintf("Showing 0 of %d", p.Total))
+	case shown == 0:
+		notes = append(notes, "No resources found")
+	default:
+		summary := fmt.Sprintf("Showing %d–%d", p.Offset+1, p.Offset+shown)
+		if p.HasTotal {
+			summary += fmt.Sprintf(" of %d", p.Total)
+		}
+		if p.Page > 0 && p.LastPage > 0 {
+			summary += fmt.Sprintf(" (page %d of %d)", p.Page, p.LastPage)
+		}
+		notes = append(notes, summary)
+	}
+
+	if links := p.Links; links != nil && (links.First != "" || links.Prev != "" || links.Next != "" || links.Last != "") {
+		for _, link := range [][2]string{{"first", links.First}, {"prev", links.Prev}, {"next", links.Next}, {"last", links.Last}} {
+			if link[1] != "" {
+				notes = append(notes, fmt.Sprintf("%s: %s", link[0], link[1]))
 			}
-			row = append(row, value)
 		}
-		fmt.Fprintln(w, strings.Join(row, "\t"))
+	} else if p.Page > 0 && p.Page < p.LastPage {
+		notes = append(notes, fmt.Sprintf("Next page: -page[number]=%d", p.Page+1))
 	}
 
-	// Flush the writer
-	w.Flush()
+	for _, key := range sortedKeys(doc.Meta) {
+		notes = append(notes, fmt.Sprintf("%s: %v", key, doc.Meta[key]))
+	}
+	return notes
 }
 
-func collectAttributeKeys(resources []*models.Resource) []string {
+// relatedColumns returns the sorted "relation.attribute" columns available
+// from the included resources linked to the given resources.
+func relatedColumns(resources []*models.Resource) []string {
 	keysMap := make(map[string]struct{})
 	for _, res := range resources {
-		for key := range res.Attributes {
-			keysMap[key] = struct{}{}
+		for name, rel := range res.Relationships {
+			for _, related := range rel.Resources {
+				for key := range related.Attributes {
+					if _, ok := api.StandardColumns[key]; !ok {
+						keysMap[name+"."+key] = struct{}{}
+					}
+				}
+			}
 		}
 	}
-	var keys []string
+	keys := make([]string, 0, len(keysMap))
 	for key := range keysMap {
 		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
+// relatedValue returns the value of a "relation.attribute" column, joining
+// the values of to-many relationships.
+func relatedValue(res *models.Resource, key string) string {
+	name, attribute, _ := strings.Cut(key, ".")
+	var values []string
+	for _, related := range res.Relationships[name].Resources {
+		if val, ok := related.Attributes[attribute]; ok {
+			values = append(values, formatCell(val, nil))
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
 func relationCommand(client *api.Client, args []string) {
 	if len(args) < 1 {
 		fmt.Println("Expected 'get', 'update', 'add', 'remove' subcommands")
@@ -652,11 +1269,18 @@ func relationCommand(client *api.Client, args []string) {
 
 func getRelationCommand(client *api.Client, args []string) {
 	getRelCmd := flag.NewFlagSet("relation get", flag.ExitOnError)
+	registerGlobalFlags(getRelCmd)
 	resourceType := getRelCmd.String("type", "", "Resource type")
 	id := getRelCmd.String("id", "", "Resource ID")
+	by := registerByFlag(getRelCmd, true)
 	relation := getRelCmd.String("relation", "", "Relation name")
 	getRelCmd.Parse(args)
 
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
 	if *resourceType == "" || *id == "" || *relation == "" {
 		getRelCmd.Usage()
 		os.Exit(1)
@@ -668,23 +1292,24 @@ func getRelationCommand(client *api.Client, args []string) {
 		os.Exit(1)
 	}
 
-	output, err := json.MarshalIndent(doc, "", "  ")
-	if err != nil {
-		utils.ErrorLogger.Println("Failed to marshal response:", err)
-		os.Exit(1)
-	}
-
-	fmt.Println(string(output))
+	render(documentView(doc))
 }
 
 func updateRelationCommand(client *api.Client, args []string) {
 	updateRelCmd := flag.NewFlagSet("relation update", flag.ExitOnError)
+	registerGlobalFlags(updateRelCmd)
 	resourceType := updateRelCmd.String("type", "", "Resource type")
 	id := updateRelCmd.String("id", "", "Resource ID")
+	by := registerByFlag(updateRelCmd, true)
 	relation := updateRelCmd.String("relation", "", "Relation name")
 	data := updateRelCmd.String("data", "", "Relation data in JSON format")
 	updateRelCmd.Parse(args)
 
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
 	if *resourceType == "" || *id == "" || *relation == "" || *data == "" {
 		updateRelCmd.Usage()
 		os.Exit(1)
@@ -698,28 +1323,32 @@ func updateRelationCommand(client *api.Client, args []string) {
 	}
 
 	doc, err := client.UpdateRelationship(*resourceType, *id, *relation, relationData)
-	if err != nil {
-		utils.ErrorLogger.Println("Failed to update relation:", err)
-		os.Exit(1)
+	if isDryRun(err) {
+		return
 	}
-
-	output, err := json.MarshalIndent(doc, "", "  ")
 	if err != nil {
-		utils.ErrorLogger.Println("Failed to marshal response:", err)
+		utils.ErrorLogger.Println("Failed to update relation:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println(string(output))
+	render(documentView(doc))
 }
 
 func addRelationCommand(client *api.Client, args []string) {
 	addRelCmd := flag.NewFlagSet("relation add", flag.ExitOnError)
+	registerGlobalFlags(addRelCmd)
 	resourceType := addRelCmd.String("type", "", "Resource type")
 	id := addRelCmd.String("id", "", "Resource ID")
+	by := registerByFlag(addRelCmd, true)
 	relation := addRelCmd.String("relation", "", "Relation name")
 	data := addRelCmd.String("data", "", "Relation data in JSON format")
 	addRelCmd.Parse(args)
 
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
 	if *resourceType == "" || *id == "" || *relation == "" || *data == "" {
 		addRelCmd.Usage()
 		os.Exit(1)
@@ -733,28 +1362,32 @@ func addRelationCommand(client *api.Client, args []string) {
 	}
 
 	doc, err := client.AddToRelationship(*resourceType, *id, *relation, relationData)
-	if err != nil {
-		utils.ErrorLogger.Println("Failed to add to relation:", err)
-		os.Exit(1)
+	if isDryRun(err) {
+		return
 	}
-
-	output, err := json.MarshalIndent(doc, "", "  ")
 	if err != nil {
-		utils.ErrorLogger.Println("Failed to marshal response:", err)
+		utils.ErrorLogger.Println("Failed to add to relation:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println(string(output))
+	render(documentView(doc))
 }
 
 func removeRelationCommand(client *api.Client, args []string) {
 	removeRelCmd := flag.NewFlagSet("relation remove", flag.ExitOnError)
+	registerGlobalFlags(removeRelCmd)
 	resourceType := removeRelCmd.String("type", "", "Resource type")
 	id := removeRelCmd.String("id", "", "Resource ID")
+	by := registerByFlag(removeRelCmd, true)
 	relation := removeRelCmd.String("relation", "", "Relation name")
 	data := removeRelCmd.String("data", "", "Relation data in JSON format")
 	removeRelCmd.Parse(args)
 
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
 	if *resourceType == "" || *id == "" || *relation == "" || *data == "" {
 		removeRelCmd.Usage()
 		os.Exit(1)
@@ -768,10 +1401,28 @@ func removeRelationCommand(client *api.Client, args []string) {
 	}
 
 	err = client.DeleteFromRelationship(*resourceType, *id, *relation, relationData)
+	if isDryRun(err) {
+		return
+	}
 	if err != nil {
 		utils.ErrorLogger.Println("Failed to remove from relation:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Relation updated successfully.")
+	render(&View{
+		Message: "Relation updated successfully.",
+		Data:    map[string]interface{}{"type": *resourceType, "id": *id, "relation": *relation, "removed": relationData},
+	})
+}
+
+// documentView shows the resources or resource identifiers of a document.
+func documentView(doc *models.Document) *View {
+	resources, err := doc.Resolve()
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to parse response:", err)
+		os.Exit(1)
+	}
+	view := resourcesView(resources, nil)
+	view.Data = doc
+	return view
 }
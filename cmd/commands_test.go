@@ -0,0 +1,176 @@
+// cmd/commands_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+)
+
+// An action on an instance gets the record as the <type>_id input, which is
+// not one of the action's input fields and must pass validation.
+func TestExecuteOnInstance(t *testing.T) {
+	utils.InitLogger(false)
+	schema := `{"Name":"ban","InFields":[{"Name":"reason","ColumnName":"reason","ColumnType":"label","DataType":"varchar(20)"}]}`
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/action":
+			action, _ := json.Marshal(map[string]interface{}{
+				"data": []interface{}{map[string]interface{}{
+					"type": "action", "id": "a1", "attributes": map[string]interface{}{"action_schema": schema},
+				}},
+			})
+			w.Write(action)
+		case r.Method == http.MethodPost && r.URL.Path == "/action/user_account/ban":
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &sent); err != nil {
+				t.Errorf("execute sent %s: %v", body, err)
+			}
+			io.WriteString(w, `[{"ResponseType":"client.notify","Attributes":{"message":"done"}}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := api.NewClient(server.URL+"/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	defer func() { os.Stdout = stdout }()
+
+	executeCommand(client, []string{"-type", "user_account", "-name", "ban", "-id", "u1", "-inputs", "reason=spam"})
+
+	want := map[string]interface{}{"user_account_id": "u1", "reason": "spam"}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("execute sent %v, want %v", sent, want)
+	}
+}
+
+// concurrentChange makes the first read of a record bump its version and
+// set an attribute, as if someone changed it right after dcli read it.
+func concurrentChange(f *fakeServer, resourceType, id, column string, value interface{}) {
+	var once sync.Once
+	path := "/api/" + resourceType + "/" + id
+	f.before = func(r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != path {
+			return
+		}
+		once.Do(func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			record := f.records[resourceType][id]
+			version, _ := record.Attributes["version"].(float64)
+			record.Attributes[column], record.Attributes["version"] = value, version+1
+		})
+	}
+}
+
+// A bulk update does not overwrite a record changed after it was listed, and
+// goes on with the others.
+func TestUpdateMatchingConflict(t *testing.T) {
+	f := newFakeServer(t)
+	f.models["user_account"] = `{"TableName":"user_account","ColumnModel":{
+		"name":{"ColumnName":"name","ColumnType":"label","DataType":"varchar(50)"},
+		"status":{"ColumnName":"status","ColumnType":"label","DataType":"varchar(20)"}
+	}}`
+	f.add("user_account", "u1", map[string]interface{}{"name": "Al", "status": "new"}, nil)
+	f.add("user_account", "u2", map[string]interface{}{"name": "Bo", "status": "new"}, nil)
+	concurrentChange(f, "user_account", "u1", "status", "banned")
+
+	logPath := filepath.Join(t.TempDir(), "update.ndjson")
+	_, stderr, code := f.run("", "update", "-type", "user_account", "-where", "status=new", "--set", "status=active", "--yes", "-log", logPath)
+	if code != 1 {
+		t.Errorf("update -where exited with %d, want 1: %s", code, stderr)
+	}
+	if got := f.record("user_account", "u1").Attributes["status"]; got != "banned" {
+		t.Errorf("u1 status = %v, want the concurrent change kept", got)
+	}
+	if got := f.record("user_account", "u2").Attributes["status"]; got != "active" {
+		t.Errorf("u2 status = %v, want active", got)
+	}
+	if patches := strings.Join(f.sent(http.MethodPatch), ","); patches != "PATCH /api/user_account/u2" {
+		t.Errorf("update -where sent %s", patches)
+	}
+	log, err := os.ReadFile(logPath)
+	if err != nil || !strings.Contains(string(log), "conflict") {
+		t.Errorf("update log %s does not report the conflict: %v", log, err)
+	}
+}
+
+func TestPreviousLinkage(t *testing.T) {
+	toOne := models.Relationship{Data: map[string]interface{}{"type": "user_account", "id": "u9"}}
+	toMany := models.Relationship{Data: []interface{}{map[string]interface{}{"type": "usergroup", "id": "g9"}}}
+	before := &models.Resource{Relationships: map[string]models.Relationship{
+		"manager_id":   {Data: map[string]interface{}{"type": "user_account", "id": "u2"}},
+		"usergroup_id": {Data: []interface{}{}},
+		"mentor_id":    {},
+	}}
+
+	got, err := previousLinkage(before, map[string]models.Relationship{"manager_id": toOne, "usergroup_id": toMany, "mentor_id": toOne})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]models.Relationship{
+		"manager_id":   {Data: models.ResourceIdentifier{Type: "user_account", ID: "u2"}},
+		"usergroup_id": {Data: []models.ResourceIdentifier{}},
+		"mentor_id":    {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("previousLinkage = %v, want %v", got, want)
+	}
+
+	// Relations the server did not return cannot be reverted
+	for _, rel := range []models.Relationship{toOne, toMany} {
+		if _, err := previousLinkage(&models.Resource{}, map[string]models.Relationship{"other_id": rel}); err == nil || !strings.Contains(err.Error(), "did not return the linkage of other_id") {
+			t.Errorf("previousLinkage of a relation the server did not return = %v", err)
+		}
+	}
+}
+
+// A bulk update logs the linkage it replaces, and the log reverts it.
+func TestUpdateMatchingRevertsLinkage(t *testing.T) {
+	f := newFakeServer(t)
+	f.models["user_account"] = `{"TableName":"user_account","ColumnModel":{
+		"name":{"ColumnName":"name","ColumnType":"label","DataType":"varchar(50)"},
+		"manager_id":{"ColumnName":"manager_id","jsonApi":"hasOne","type":"user_account"}
+	}}`
+	manager := map[string]interface{}{"type": "user_account", "id": "u2"}
+	f.add("user_account", "u1", map[string]interface{}{"name": "Al"}, map[string]interface{}{"manager_id": manager})
+	f.add("user_account", "u3", map[string]interface{}{"name": "Al"}, nil)
+
+	logPath := filepath.Join(t.TempDir(), "update.ndjson")
+	_, stderr, code := f.run("", "update", "-type", "user_account", "-where", "name=Al", "--rel", "manager_id=user_account:u9", "--yes", "-log", logPath)
+	if code != 1 {
+		t.Errorf("update -where exited with %d, want 1 for u3: %s", code, stderr)
+	}
+	if patches := strings.Join(f.sent(http.MethodPatch), ","); patches != "PATCH /api/user_account/u1" {
+		t.Errorf("update -where sent %s, want no change to u3, whose linkage is unknown", patches)
+	}
+
+	_, stderr, code = f.run("", "update", "-f", logPath)
+	if code != 0 {
+		t.Fatalf("update -f %s exited with %d: %s", logPath, code, stderr)
+	}
+	if got := f.record("user_account", "u1").Relationships["manager_id"]; !reflect.DeepEqual(got, manager) {
+		t.Errorf("after the revert, u1 manager_id = %v, want %v", got, manager)
+	}
+	if _, ok := f.record("user_account", "u3").Relationships["manager_id"]; ok {
+		t.Errorf("the revert linked u3")
+	}
+}
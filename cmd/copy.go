@@ -0,0 +1,275 @@
+// cmd/copy.go
+
+package main
+
+import (
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// copyResult is a record that was copied and the ID of its copy.
+type copyResult struct {
+	Type string `json:"type"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// copier copies records, and the records of the relations it follows, from
+// one client to another, remembering the copy of every record so that links
+// between copied records point at the copies.
+type copier struct {
+	from, to     *api.Client
+	crossProfile bool
+	follow       []string // Names or kinds, such as hasMany, of the relations whose records are copied too
+
+	models map[string]*api.TableInfo
+	copies map[string]string // Source "type/id" to the ID of its copy
+	exists map[string]bool   // Whether a source "type/id" exists at the destination
+	copied []copyResult
+	notes  []string
+}
+
+func copyCommand(client *api.Client, config *utils.Config, args []string) {
+	copyCmd := flag.NewFlagSet("cp", flag.ExitOnError)
+	registerGlobalFlags(copyCmd)
+	resourceType := copyCmd.String("type", "", "Resource type")
+	id := copyCmd.String("id", "", "ID of the record to copy")
+	by := registerByFlag(copyCmd, true)
+	toProfile := copyCmd.String("to-profile", "", "Configuration profile to create the copy in (default: the current one)")
+	withRelations := copyCmd.String("with-relations", "", "Comma-separated relation names or kinds, e.g. hasMany, whose records are copied too, recursively")
+	var sets, setFiles stringList
+	copyCmd.Var(&sets, "set", "Set an attribute of the copy, as column=value or column:=json (repeatable)")
+	copyCmd.Var(&setFiles, "set-file", "Set an attribute of the copy to the contents of a file, as column=@path (repeatable)")
+	copyCmd.Parse(args)
+
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+	if *resourceType == "" || *id == "" {
+		copyCmd.Usage()
+		os.Exit(1)
+	}
+
+	c := &copier{
+		from:   client,
+		to:     client,
+		follow: parseColumnList(*withRelations),
+		models: make(map[string]*api.TableInfo),
+		copies: make(map[string]string),
+		exists: make(map[string]bool),
+	}
+	if *toProfile != "" {
+		var err error
+		if c.to, err = connect(config, *toProfile, "cp "+strings.Join(args, " ")); err != nil {
+			utils.ErrorLogger.Println("Failed to create API client:", err)
+			os.Exit(1)
+		}
+		c.crossProfile = true
+	}
+
+	model, err := c.model(*resourceType)
+	if err != nil {
+		utils.ErrorLogger.Printf("Failed to fetch the model of %s: %v", *resourceType, err)
+		os.Exit(1)
+	}
+	for _, follow := range c.follow {
+		known := false
+		for _, name := range relationNames(model) {
+			known = known || follow == name || follow == model.ColumnModel[name].JsonApi
+		}
+		if !known {
+			utils.ErrorLogger.Printf("Invalid --with-relations: %s has no relation named or of kind %q, relations: %s",
+				*resourceType, follow, strings.Join(relationNames(model), ", "))
+			os.Exit(1)
+		}
+	}
+	assignments, err := parseAssignments(sets, setFiles)
+	if err != nil {
+		utils.ErrorLogger.Println("Invalid input:", err)
+		os.Exit(1)
+	}
+	overrides := make(map[string]interface{}, len(assignments))
+	for _, a := range assignments {
+		if overrides[a.column], err = assignmentValue(a, model); err != nil {
+			utils.ErrorLogger.Println("Invalid input:", err)
+			os.Exit(1)
+		}
+	}
+
+	_, err = c.copy(*resourceType, *id, overrides)
+	if isDryRun(err) {
+		return
+	}
+
+	for _, note := range c.notes {
+		fmt.Fprintln(os.Stderr, "Note:", note)
+	}
+	view := &View{Title: "Copied", Columns: columnNames("Type", "From", "To"), Data: c.copied}
+	for _, copied := range c.copied {
+		view.Rows = append(view.Rows, []string{copied.Type, copied.From, copied.To})
+	}
+	if len(c.copied) > 0 {
+		render(view)
+	}
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to copy:", err)
+		os.Exit(1)
+	}
+}
+
+func (c *copier) model(resourceType string) (*api.TableInfo, error) {
+	if model, ok := c.models[resourceType]; ok {
+		return model, nil
+	}
+	model, err := c.from.GetEntityModel(resourceType)
+	if err != nil {
+		return nil, err
+	}
+	if model.TableName == "" {
+		model.TableName = resourceType
+	}
+	c.models[resourceType] = model
+	return model, nil
+}
+
+// follows reports whether the records of a relation are copied too.
+func (c *copier) follows(name string, col api.ColumnInfo) bool {
+	for _, follow := range c.follow {
+		if follow == name || follow == col.JsonApi {
+			return true
+		}
+	}
+	return false
+}
+
+// copy creates a copy of a record without its server-managed columns and
+// permission, with the overrides applied, then copies the records of the
+// followed relations and links them to it. It returns the ID of the copy.
+func (c *copier) copy(resourceType, id string, overrides map[string]interface{}) (string, error) {
+	key := resourceType + "/" + id
+	if copyID, ok := c.copies[key]; ok {
+		return copyID, nil
+	}
+	model, err := c.model(resourceType)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch the model of %s: %w", resourceType, err)
+	}
+	relations := relationNames(model)
+	doc, err := c.from.ReadDocument(resourceType, id, strings.Join(relations, ","))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s %s: %w", resourceType, id, err)
+	}
+	resources, err := doc.Resolve()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s %s: %w", resourceType, id, err)
+	}
+	if len(resources) == 0 {
+		return "", fmt.Errorf("%s %s not found", resourceType, id)
+	}
+	source := resources[0]
+
+	duplicate := &models.Resource{Type: resourceType, Attributes: make(map[string]interface{}, len(source.Attributes))}
+	for name, value := range source.Attributes {
+		duplicate.Attributes[name] = value
+	}
+	for _, name := range serverManagedColumns {
+		delete(duplicate.Attributes, name)
+	}
+	// The copy gets the permission the destination gives new records
+	delete(duplicate.Attributes, "permission")
+	for name, value := range overrides {
+		duplicate.Attributes[name] = value
+	}
+
+	followed := make(map[string]models.Relationship)
+	for _, name := range relations {
+		rel, ok := source.Relationships[name]
+		switch {
+		case !ok:
+		case c.follows(name, model.ColumnModel[name]):
+			followed[name] = rel
+		case rel.IsToMany():
+			// Linking the same records would take them away from the original
+			if len(rel.Identifiers()) > 0 {
+				c.notes = append(c.notes, fmt.Sprintf("%s %s: %s not copied; add it to --with-relations to copy its records", resourceType, id, name))
+			}
+		default:
+			if linkage, ok := c.link(resourceType, id, name, rel); ok {
+				if duplicate.Relationships == nil {
+					duplicate.Relationships = make(map[string]models.Relationship)
+				}
+				duplicate.Relationships[name] = linkage
+			}
+		}
+	}
+
+	created, err := createLinked(c.to, duplicate)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy %s %s: %w", resourceType, id, err)
+	}
+	c.copies[key] = created.ID
+	c.copied = append(c.copied, copyResult{Type: resourceType, From: id, To: created.ID})
+
+	names := make([]string, 0, len(followed))
+	for name := range followed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	children := make(map[string]models.Relationship, len(followed))
+	for _, name := range names {
+		rel := followed[name]
+		identifiers := []models.ResourceIdentifier{}
+		for _, identifier := range rel.Identifiers() {
+			copyID, err := c.copy(identifier.Type, identifier.ID, nil)
+			if err != nil {
+				return created.ID, err
+			}
+			identifiers = append(identifiers, models.ResourceIdentifier{Type: identifier.Type, ID: copyID})
+		}
+		switch {
+		case rel.IsToMany():
+			children[name] = models.Relationship{Data: identifiers}
+		case len(identifiers) > 0:
+			children[name] = models.Relationship{Data: identifiers[0]}
+		}
+	}
+	if err := setRelationships(c.to, resourceType, created.ID, children, true); err != nil {
+		return created.ID, fmt.Errorf("copied %s %s as %s, but %w", resourceType, id, created.ID, err)
+	}
+	return created.ID, nil
+}
+
+// link returns the linkage of a to-one relation of the copy: the copy of
+// the linked record when it was copied, otherwise the record itself, unless
+// it does not exist at another destination.
+func (c *copier) link(resourceType, id, name string, rel models.Relationship) (models.Relationship, bool) {
+	identifiers := rel.Identifiers()
+	if len(identifiers) == 0 {
+		return models.Relationship{}, false
+	}
+	linked := identifiers[0]
+	key := linked.Type + "/" + linked.ID
+	if copyID, ok := c.copies[key]; ok {
+		return models.Relationship{Data: models.ResourceIdentifier{Type: linked.Type, ID: copyID}}, true
+	}
+	if c.crossProfile {
+		exists, checked := c.exists[key]
+		if !checked {
+			_, err := c.to.Read(linked.Type, linked.ID)
+			exists = err == nil
+			c.exists[key] = exists
+		}
+		if !exists {
+			c.notes = append(c.notes, fmt.Sprintf("%s %s: %s left empty, %s %s is not at the destination", resourceType, id, name, linked.Type, linked.ID))
+			return models.Relationship{}, false
+		}
+	}
+	return models.Relationship{Data: models.ResourceIdentifier{Type: linked.Type, ID: linked.ID}}, true
+}
@@ -0,0 +1,238 @@
+// cmd/diff.go
+
+package main
+
+import (
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// fieldChange is a field whose value on the server differs from a manifest.
+type fieldChange struct {
+	Field        string      `json:"field"`
+	Relationship bool        `json:"relationship,omitempty"`
+	Old          interface{} `json:"old"`
+	New          interface{} `json:"new"`
+}
+
+// resourceDiff compares a manifest with the record it describes.
+type resourceDiff struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id,omitempty"`
+	Match   string        `json:"match,omitempty"`
+	Source  string        `json:"source"`
+	Status  string        `json:"status"` // new, changed or unchanged
+	Changes []fieldChange `json:"changes,omitempty"`
+
+	unchanged map[string]interface{} // Fields equal on both sides, shown as context
+}
+
+// diffCommand compares manifests with the server and exits with 1 when they
+// differ and 2 on errors, like diff(1).
+func diffCommand(client *api.Client, args []string) {
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	registerGlobalFlags(diffCmd)
+	path := diffCmd.String("f", "", "Manifest file, or directory of .json, .yaml and .ndjson manifests; '-' for stdin")
+	resourceType := diffCmd.String("type", "", "Resource type of manifests that do not declare one")
+	key := diffCmd.String("key", "", "Comma-separated identity columns to match existing records by, unless a manifest declares meta.identity")
+	diffCmd.Parse(args)
+
+	if *path == "" {
+		diffCmd.Usage()
+		os.Exit(2)
+	}
+
+	resources, sources, err := readManifests(*path, *resourceType)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to read manifests:", err)
+		os.Exit(2)
+	}
+
+	var diffs []resourceDiff
+	differ := 0
+	for i, res := range resources {
+		diff, err := diffManifest(client, res, sources[i], identityColumns(res, parseColumnList(*key)))
+		if err != nil {
+			utils.ErrorLogger.Printf("Failed to compare %s: %v", sources[i], err)
+			os.Exit(2)
+		}
+		if diff.Status != "unchanged" {
+			differ++
+		}
+		diffs = append(diffs, diff)
+	}
+
+	if isTableOutput() {
+		terminal := stdoutTerminal()
+		var b strings.Builder
+		for _, diff := range diffs {
+			writeUnifiedDiff(&b, diff, terminal.useColor())
+		}
+		writeOutput([]byte(b.String()), terminal)
+		fmt.Fprintf(os.Stderr, "%d of %d records differ.\n", differ, len(diffs))
+	} else {
+		render(&View{Data: diffs})
+	}
+	if differ > 0 {
+		os.Exit(1)
+	}
+}
+
+// diffManifest compares the attributes and relationship linkage of a
+// manifest with the record it matches, which findExisting reads with the
+// linkage of the manifest's relations.
+func diffManifest(client *api.Client, res *models.Resource, source string, identity []string) (resourceDiff, error) {
+	existing, match, err := findExisting(client, res, identity)
+	if err != nil {
+		return resourceDiff{}, err
+	}
+	diff := resourceDiff{Type: res.Type, Match: match, Source: source, Status: "new", unchanged: map[string]interface{}{}}
+	if existing != nil {
+		diff.ID = existing.ID
+		diff.Status = "unchanged"
+	}
+
+	for key, value := range res.Attributes {
+		var current interface{}
+		ok := false
+		if existing != nil {
+			current, ok = existing.Attributes[key]
+			if current, err = normalizeJSON(current); err != nil {
+				return diff, err
+			}
+		}
+		if ok && reflect.DeepEqual(current, value) {
+			diff.unchanged[key] = value
+			continue
+		}
+		diff.Changes = append(diff.Changes, fieldChange{Field: key, Old: current, New: value})
+	}
+
+	for name, rel := range res.Relationships {
+		desired, err := linkageKeys(client, rel)
+		if err != nil {
+			return diff, fmt.Errorf("relationship %s: %w", name, err)
+		}
+		var current interface{}
+		if existing != nil {
+			if currentRel, ok := existing.Relationships[name]; ok && currentRel.Data != nil {
+				current = identifierKeys(currentRel.Identifiers(), currentRel.IsToMany())
+			}
+		}
+		if current != nil && reflect.DeepEqual(current, desired) {
+			diff.unchanged[name] = desired
+			continue
+		}
+		diff.Changes = append(diff.Changes, fieldChange{Field: name, Relationship: true, Old: current, New: desired})
+	}
+
+	sort.Slice(diff.Changes, func(i, j int) bool { return diff.Changes[i].Field < diff.Changes[j].Field })
+	if existing != nil && len(diff.Changes) > 0 {
+		diff.Status = "changed"
+	}
+	return diff, nil
+}
+
+// linkageKeys describes the linkage of a manifest relationship as type/id
+// strings, a list for to-many relationships. Lookups that match no record
+// are described by their type and columns.
+func linkageKeys(client *api.Client, rel models.Relationship) (interface{}, error) {
+	items, toMany := rel.Data.([]interface{})
+	if !toMany {
+		items = []interface{}{rel.Data}
+	}
+	var identifiers []models.ResourceIdentifier
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		linkage, err := resolveLinkage(client, models.Relationship{Data: item})
+		if errors.Is(err, errNoMatch) {
+			identifiers = append(identifiers, models.ResourceIdentifier{Type: "(no match)", ID: plainValue(item)})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		identifiers = append(identifiers, linkageIdentifiers(linkage)...)
+	}
+	return identifierKeys(identifiers, toMany), nil
+}
+
+func identifierKeys(identifiers []models.ResourceIdentifier, toMany bool) interface{} {
+	keys := make([]interface{}, len(identifiers))
+	for i, identifier := range identifiers {
+		keys[i] = identifier.Type + "/" + identifier.ID
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].(string) < keys[j].(string) })
+	if toMany {
+		return keys
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return keys[0]
+}
+
+// writeUnifiedDiff writes the differences of a record as a unified diff with
+// one line per field. Unchanged fields of the manifest are shown as context.
+func writeUnifiedDiff(b *strings.Builder, diff resourceDiff, color bool) {
+	if diff.Status == "unchanged" {
+		return
+	}
+
+	old := fmt.Sprintf("%s %s (server)", diff.Type, diff.ID)
+	if diff.Status == "new" {
+		old = "/dev/null"
+	}
+	name := diff.ID
+	if name == "" {
+		name = "(new)"
+	}
+	b.WriteString(colorize(color, colorBold, "--- "+old) + "\n")
+	b.WriteString(colorize(color, colorBold, fmt.Sprintf("+++ %s %s (%s)", diff.Type, name, diff.Source)) + "\n")
+	if diff.Match != "" {
+		b.WriteString(colorize(color, colorCyan, "@@ "+diff.Match+" @@") + "\n")
+	}
+
+	changes := make(map[string]fieldChange, len(diff.Changes))
+	fields := make([]string, 0, len(diff.Changes)+len(diff.unchanged))
+	for _, change := range diff.Changes {
+		changes[change.Field] = change
+		fields = append(fields, change.Field)
+	}
+	for field := range diff.unchanged {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		change, changed := changes[field]
+		if !changed {
+			fmt.Fprintf(b, " %s: %s\n", field, diffValue(diff.unchanged[field]))
+			continue
+		}
+		if diff.Status != "new" {
+			b.WriteString(colorize(color, colorRed, fmt.Sprintf("-%s: %s", field, diffValue(change.Old))) + "\n")
+		}
+		b.WriteString(colorize(color, colorGreen, fmt.Sprintf("+%s: %s", field, diffValue(change.New))) + "\n")
+	}
+}
+
+// diffValue formats a field value as compact JSON.
+func diffValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,54 @@
+// cmd/diff_test.go
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func diffServer(t *testing.T) *fakeServer {
+	f := newFakeServer(t)
+	f.add("usergroup", "g1", map[string]interface{}{"name": "staff"}, nil)
+	f.add("user_account", "u1", map[string]interface{}{"email": "al@x.com", "name": "Al"}, map[string]interface{}{
+		"usergroup_id": []interface{}{map[string]interface{}{"type": "usergroup", "id": "g1"}},
+		"manager_id":   map[string]interface{}{"type": "user_account", "id": "u2"},
+	})
+	return f
+}
+
+// A manifest the server matches, relationships included, shows no drift.
+func TestDiffInSync(t *testing.T) {
+	f := diffServer(t)
+	manifest := writeManifest(t, `{"type":"user_account","attributes":{"email":"al@x.com","name":"Al"},"relationships":{
+		"usergroup_id":{"data":[{"type":"usergroup","meta":{"match":{"name":"staff"}}}]},
+		"manager_id":{"data":{"type":"user_account","id":"u2"}}
+	}}`)
+
+	stdout, stderr, code := f.run("", "diff", "-f", manifest, "-key", "email")
+	if code != 0 {
+		t.Errorf("diff exited with %d, want 0: %s%s", code, stdout, stderr)
+	}
+	if stdout != "" || !strings.Contains(stderr, "0 of 1 records differ.") {
+		t.Errorf("diff printed %q, %q", stdout, stderr)
+	}
+}
+
+func TestDiffRelationshipDrift(t *testing.T) {
+	f := diffServer(t)
+	manifest := writeManifest(t, `{"type":"user_account","id":"u1","attributes":{"name":"Al"},"relationships":{
+		"usergroup_id":{"data":[]},
+		"manager_id":{"data":{"type":"user_account","id":"u2"}}
+	}}`)
+
+	stdout, stderr, code := f.run("", "diff", "-f", manifest)
+	if code != 1 {
+		t.Errorf("diff exited with %d, want 1: %s%s", code, stdout, stderr)
+	}
+	want := []string{` manager_id: "user_account/u2"`, `-usergroup_id: ["usergroup/g1"]`, `+usergroup_id: []`}
+	for _, line := range want {
+		if !strings.Contains(stdout, line+"\n") {
+			t.Errorf("diff printed\n%s\nwant a line %q", stdout, line)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// cmd/dryrun.go
+
+package main
+
+import (
+	"bytes"
+	"dcli/api"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// dryRunRequest is a request stopped by --dry-run, as printed.
+type dryRunRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    interface{}       `json:"body,omitempty"`
+	Curl    string            `json:"curl"`
+}
+
+// interceptDryRun is the api.Client.DryRun hook: with --dry-run, it prints
+// every request that would change data instead of letting it be sent.
+func interceptDryRun(req *http.Request, body []byte) bool {
+	if !globalOptions.dryRun {
+		return false
+	}
+	render(dryRunView(req, body))
+	return true
+}
+
+// isDryRun reports whether err is a request stopped by --dry-run, which
+// commands treat as the end of a successful run.
+func isDryRun(err error) bool {
+	return errors.Is(err, api.ErrDryRun)
+}
+
+// dryRunView shows the method, URL, headers and body of a request, with
+// credentials redacted, and an equivalent curl command.
+func dryRunView(req *http.Request, body []byte) *View {
+	headers := redactedHeaders(req.Header)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	request := dryRunRequest{Method: req.Method, URL: req.URL.String(), Headers: headers}
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s %s\n", req.Method, req.URL)
+	for _, name := range names {
+		fmt.Fprintf(&text, "%s: %s\n", name, headers[name])
+	}
+	if len(body) > 0 {
+		var decoded interface{}
+		if json.Unmarshal(body, &decoded) == nil {
+			request.Body = decoded
+		} else {
+			request.Body = string(body)
+		}
+		var indented bytes.Buffer
+		if json.Indent(&indented, body, "", "  ") == nil {
+			body = indented.Bytes()
+		}
+		fmt.Fprintf(&text, "\n%s\n", body)
+	}
+
+	curl := []string{"curl -X " + req.Method + " " + shellQuote(req.URL.String())}
+	for _, name := range names {
+		curl = append(curl, "-H "+shellQuote(name+": "+headers[name]))
+	}
+	if len(body) > 0 {
+		curl = append(curl, "--data-binary "+shellQuote(string(body)))
+	}
+	request.Curl = strings.Join(curl, " \\\n  ")
+	fmt.Fprintf(&text, "\n%s", request.Curl)
+
+	return &View{Message: text.String(), Data: request}
+}
+
+// redactedHeaders returns the headers of a request with the values of those
+// carrying credentials replaced. The scheme of Authorization is kept.
+func redactedHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		lower := strings.ToLower(name)
+		switch {
+		case lower == "authorization" || lower == "proxy-authorization":
+			scheme, _, found := strings.Cut(value, " ")
+			value = "<redacted>"
+			if found {
+				value = scheme + " <redacted>"
+			}
+		case lower == "cookie" || strings.Contains(lower, "token") || strings.Contains(lower, "secret") || strings.Contains(lower, "api-key"):
+			value = "<redacted>"
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
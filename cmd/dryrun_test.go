@@ -0,0 +1,80 @@
+// cmd/dryrun_test.go
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Under --dry-run, bulk changes and apply print every request they would
+// send, and send none.
+func TestDryRunPrintsEveryRequest(t *testing.T) {
+	f := newFakeServer(t)
+	f.models["user_account"] = `{"TableName":"user_account","ColumnModel":{
+		"email":{"ColumnName":"email","ColumnType":"email","DataType":"varchar(80)"},
+		"status":{"ColumnName":"status","ColumnType":"label","DataType":"varchar(20)"}
+	}}`
+	f.add("user_account", "u1", map[string]interface{}{"email": "al@x.com", "status": "new"}, nil)
+	f.add("user_account", "u2", map[string]interface{}{"email": "bo@x.com", "status": "new"}, nil)
+	manifest := writeManifest(t, `[
+		{"type":"user_account","attributes":{"email":"al@x.com","status":"active"}},
+		{"type":"user_account","attributes":{"email":"cy@x.com","status":"new"}}
+	]`)
+
+	tests := []struct {
+		args    []string
+		want    []string
+		message string
+	}{
+		{
+			[]string{"--dry-run", "delete", "-type", "user_account", "-where", "status=new"},
+			[]string{"DELETE " + f.url + "/api/user_account/u1", "DELETE " + f.url + "/api/user_account/u2"},
+			"Dry run, nothing deleted.",
+		},
+		{
+			[]string{"--dry-run", "update", "-type", "user_account", "-where", "status=new", "--set", "status=active"},
+			[]string{"PATCH " + f.url + "/api/user_account/u1", "PATCH " + f.url + "/api/user_account/u2"},
+			"Dry run, nothing updated.",
+		},
+		{
+			[]string{"--dry-run", "apply", "-f", manifest, "-key", "email"},
+			[]string{"PATCH " + f.url + "/api/user_account/u1", "POST " + f.url + "/api/user_account"},
+			"Dry run, nothing applied.",
+		},
+	}
+	for _, tt := range tests {
+		stdout, stderr, code := f.run("", tt.args...)
+		command := strings.Join(tt.args, " ")
+		if code != 0 {
+			t.Errorf("%s exited with %d: %s", command, code, stderr)
+			continue
+		}
+		for _, request := range tt.want {
+			if strings.Count(stdout, request+"\n") != 1 {
+				t.Errorf("%s printed\n%s\nwant %s once", command, stdout, request)
+			}
+		}
+		if strings.Count(stdout, "curl -X") != len(tt.want) {
+			t.Errorf("%s printed %d curl commands, want %d", command, strings.Count(stdout, "curl -X"), len(tt.want))
+		}
+		if !strings.Contains(stderr, tt.message) {
+			t.Errorf("%s printed %q, want %q", command, stderr, tt.message)
+		}
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPatch, http.MethodDelete} {
+		if sent := f.sent(method); len(sent) > 0 {
+			t.Errorf("dry runs sent %v", sent)
+		}
+	}
+	if logs, _ := filepath.Glob(filepath.Join(f.home, "*.ndjson")); len(logs) > 0 {
+		t.Errorf("the dry run of a bulk update wrote %v", logs)
+	}
+	if _, err := os.Stat(filepath.Join(f.home, ".dcli", "journal")); !os.IsNotExist(err) {
+		t.Errorf("dry runs wrote to the journal: %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+// cmd/edit.go
+
+package main
+
+import (
+	"bytes"
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func editCommand(client *api.Client, args []string) {
+	editCmd := flag.NewFlagSet("edit", flag.ExitOnError)
+	registerGlobalFlags(editCmd)
+	resourceType := editCmd.String("type", "", "Resource type")
+	id := editCmd.String("id", "", "Resource ID")
+	by := registerByFlag(editCmd, true)
+	format := editCmd.String("format", "yaml", "Format to edit the attributes in: yaml or json")
+	noValidate := editCmd.Bool("no-validate", false, "Send the changes without checking them against the entity model")
+	editCmd.Parse(args)
+
+	if err := resolveID(client, *resourceType, id, *by); err != nil {
+		utils.ErrorLogger.Println("Failed to find the record:", err)
+		os.Exit(1)
+	}
+
+	if *resourceType == "" || *id == "" || (*format != "yaml" && *format != "json") {
+		editCmd.Usage()
+		os.Exit(1)
+	}
+
+	original, err := client.Read(*resourceType, *id)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to read resource:", err)
+		os.Exit(1)
+	}
+	model := loadModel(client, *resourceType)
+	version, versioned := api.ResourceVersion(original)
+
+	editable := make(map[string]interface{})
+	var order []string
+	for _, col := range attributeColumns([]*models.Resource{original}, model) {
+		if !isHiddenColumn(col.Name, model) {
+			editable[col.Name] = original.Attributes[col.Name]
+			order = append(order, col.Name)
+		}
+	}
+	body, err := encodeAttributes(editable, order, *format)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to encode attributes:", err)
+		os.Exit(1)
+	}
+
+	file, err := os.CreateTemp("", "dcli-edit-*."+*format)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to create temporary file:", err)
+		os.Exit(1)
+	}
+	file.Close()
+	path := file.Name()
+	defer os.Remove(path)
+
+	header := fmt.Sprintf("# Editing %s %s. Lines starting with '#' at the top are ignored.\n"+
+		"# Save an empty file to cancel. Removed attributes are left unchanged;\n"+
+		"# set an attribute to null to clear it.\n", *resourceType, *id)
+	content := header + string(body)
+
+	for {
+		edited, err := runEditor(path, content)
+		if err != nil {
+			utils.ErrorLogger.Println("Failed to edit resource:", err)
+			os.Remove(path)
+			os.Exit(1)
+		}
+		body := stripLeadingComments(edited)
+		if edited == content || strings.TrimSpace(body) == "" {
+			fmt.Fprintln(os.Stderr, "Edit cancelled, no changes made.")
+			return
+		}
+
+		changes, err := editedChanges(editable, body, *format)
+		if err == nil && len(changes) == 0 {
+			fmt.Fprintln(os.Stderr, "No changes made.")
+			return
+		}
+		if err == nil && !*noValidate && model != nil {
+			err = model.Validate(changes, false)
+		}
+		if err == nil {
+			changed := &models.Resource{Type: *resourceType, ID: *id, Attributes: changes}
+			var updated *models.Resource
+			if versioned {
+				updated, err = client.UpdateIfVersion(changed, version)
+			} else {
+				updated, err = client.Update(changed)
+			}
+			if err == nil {
+				render(singleResourceView(updated, model))
+				return
+			}
+			if isDryRun(err) {
+				return
+			}
+
+			// Editing again would overwrite the other change, so keep the
+			// edits for the user to reapply
+			var conflict *api.ConflictError
+			if errors.As(err, &conflict) {
+				utils.ErrorLogger.Println("Failed to update resource:", err)
+				utils.ErrorLogger.Println("Your edits are kept in", path)
+				os.Exit(1)
+			}
+		}
+
+		// Reopen the editor with the error above the edited attributes
+		content = header + commentLines("Error: "+err.Error()) + "#\n" + body
+	}
+}
+
+// encodeAttributes encodes attributes as YAML or indented JSON, keeping the
+// keys in the given order.
+func encodeAttributes(attrs map[string]interface{}, order []string, format string) ([]byte, error) {
+	generic, err := toGeneric(attrs)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := generic.(map[string]interface{})
+
+	if format == "json" {
+		var b bytes.Buffer
+		b.WriteString("{")
+		for i, key := range order {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			name, _ := json.Marshal(key)
+			value, err := json.MarshalIndent(values[key], "  ", "  ")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, "\n  %s: %s", name, value)
+		}
+		b.WriteString("\n}\n")
+		return b.Bytes(), nil
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range order {
+		var value yaml.Node
+		if err := value.Encode(values[key]); err != nil {
+			return nil, err
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &value)
+	}
+	return yaml.Marshal(root)
+}
+
+// editedChanges parses the edited attributes and returns those that differ
+// from the original ones.
+func editedChanges(original map[string]interface{}, body, format string) (map[string]interface{}, error) {
+	var documents []interface{}
+	var err error
+	if format == "json" {
+		documents, err = decodeJSONDocuments([]byte(body))
+	} else {
+		documents, err = decodeYAMLDocuments([]byte(body))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", format, err)
+	}
+	if len(documents) != 1 {
+		return nil, fmt.Errorf("expected one %s object, found %d documents", format, len(documents))
+	}
+	edited, ok := documents[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a %s object of attributes", format)
+	}
+
+	changes := make(map[string]interface{})
+	for key, value := range edited {
+		before, err := normalizeJSON(original[key])
+		if err != nil {
+			return nil, err
+		}
+		if _, existed := original[key]; !existed || !reflect.DeepEqual(before, value) {
+			changes[key] = value
+		}
+	}
+	return changes, nil
+}
+
+// normalizeJSON converts a value to the types JSON decoding produces, so
+// that it can be compared with decoded input.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// runEditor writes content to path, opens it in the user's editor and
+// returns the saved content.
+func runEditor(path, content string) (string, error) {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return "", err
+	}
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor[0], err)
+	}
+	edited, err := os.ReadFile(path)
+	return string(edited), err
+}
+
+// editorCommand returns the editor from $VISUAL or $EDITOR, falling back to
+// vi, or notepad on Windows.
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(name)); len(editor) > 0 {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// stripLeadingComments removes the comment lines at the top of an edited
+// file.
+func stripLeadingComments(content string) string {
+	for strings.HasPrefix(content, "#") {
+		i := strings.Index(content, "\n")
+		if i < 0 {
+			return ""
+		}
+		content = content[i+1:]
+	}
+	return content
+}
+
+func commentLines(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		b.WriteString("# " + line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,360 @@
+// cmd/export.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportColumn is a column of an export file: the resource ID, an attribute
+// or a relationship.
+type exportColumn struct {
+	name     string
+	relation bool
+}
+
+// exportWriter writes the records of an export in one file format.
+type exportWriter interface {
+	writeHeader(columns []exportColumn) error
+	writeRecord(columns []exportColumn, values []interface{}) error
+	close() error
+}
+
+func exportCommand(client *api.Client, args []string) {
+	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+	resourceType := exportCmd.String("type", "", "Resource type to export")
+	path := exportCmd.String("o", "-", "File to write, '-' for stdout")
+	format := exportCmd.String("format", "", "File format: csv, ndjson or json (default: from the file extension, csv for stdout)")
+	filters := registerFilterFlags(exportCmd)
+	sortFields := exportCmd.String("sort", "", "Sort fields, e.g., 'name,-created_at'")
+	pageSize := exportCmd.Int("page-size", 100, "Number of records to fetch per request")
+	columns := exportCmd.String("columns", "", "Comma-separated columns to export, in order (default: every column in schema order)")
+	relations := exportCmd.String("relations", "ids", "How to export relationships: ids (reference IDs), embed (the related records) or none")
+	exportCmd.Parse(args)
+
+	if *resourceType == "" || (*relations != "ids" && *relations != "embed" && *relations != "none") {
+		exportCmd.Usage()
+		os.Exit(1)
+	}
+	fileFormat, err := exportFormat(*path, *format)
+	if err != nil {
+		utils.ErrorLogger.Println("Invalid format:", err)
+		os.Exit(1)
+	}
+
+	options := &api.ListOptions{Page: map[string]string{"size": fmt.Sprint(*pageSize)}, Sort: *sortFields}
+	if err := filters.apply(options); err != nil {
+		utils.ErrorLogger.Println("Invalid filter:", err)
+		os.Exit(1)
+	}
+
+	model, err := client.GetEntityModel(*resourceType)
+	if err != nil {
+		utils.ErrorLogger.Printf("Failed to fetch the model of %s: %v", *resourceType, err)
+		os.Exit(1)
+	}
+	exportColumns, err := selectExportColumns(model, parseColumnList(*columns), *relations != "none")
+	if err != nil {
+		utils.ErrorLogger.Println("Invalid columns:", err)
+		os.Exit(1)
+	}
+
+	// The linkage of relationships is only sent for included relations
+	var included []string
+	for _, col := range exportColumns {
+		if col.relation {
+			included = append(included, col.name)
+		}
+	}
+	options.Include = strings.Join(included, ",")
+
+	out := os.Stdout
+	if *path != "-" {
+		if out, err = os.Create(*path); err != nil {
+			utils.ErrorLogger.Println("Failed to create output file:", err)
+			os.Exit(1)
+		}
+	}
+	buffered := bufio.NewWriter(out)
+	writer := newExportWriter(fileFormat, buffered)
+
+	// Progress would mix with the records when they go to the terminal
+	showProgress := *path != "-" && stderrIsTerminal()
+	total := 0
+	if showProgress {
+		if total, err = client.Count(*resourceType, options); err != nil {
+			utils.DebugLogger.Printf("Failed to count %s: %v", *resourceType, err)
+		}
+	}
+	bar := startProgress(total, showProgress)
+
+	err = writer.writeHeader(exportColumns)
+	if err == nil {
+		err = client.ListAll(*resourceType, options, func(page []*models.Resource) error {
+			for _, res := range page {
+				if err := writer.writeRecord(exportColumns, exportValues(res, exportColumns, *relations)); err != nil {
+					return err
+				}
+				bar.advance()
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = writer.close()
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	elapsed := bar.stop()
+	if *path != "-" {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to export resources:", err)
+		if *path != "-" {
+			os.Remove(*path)
+		}
+		os.Exit(1)
+	}
+
+	destination := *path
+	if destination == "-" {
+		destination = "stdout"
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d %s records to %s in %s (%.1f records/s).\n",
+		bar.processed(), *resourceType, destination, elapsed.Round(time.Millisecond), throughput(bar.processed(), elapsed))
+}
+
+// exportFormat returns the format of an export file, from the -format flag
+// or the file extension.
+func exportFormat(path, format string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ndjson", ".jsonl":
+			format = "ndjson"
+		case ".json":
+			format = "json"
+		case ".csv", "":
+			format = "csv"
+		default:
+			return "", fmt.Errorf("cannot tell the format of %s; pass -format", path)
+		}
+	}
+	switch format {
+	case "csv", "ndjson", "json":
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected csv, ndjson or json", format)
+}
+
+// selectExportColumns returns the columns to export: the ID, then the
+// attributes and, with withRelations, the relations of the entity in schema
+// order, or the requested columns in the order given.
+func selectExportColumns(model *api.TableInfo, requested []string, withRelations bool) ([]exportColumn, error) {
+	available := []exportColumn{{name: "id"}}
+	for _, name := range model.ColumnOrder {
+		col := model.ColumnModel[name]
+		switch {
+		case name == "id" || col.ExcludeFromApi || col.ColumnType == "hidden":
+		case col.JsonApi == "":
+			available = append(available, exportColumn{name: name})
+		case withRelations:
+			available = append(available, exportColumn{name: name, relation: true})
+		}
+	}
+	if len(requested) == 0 {
+		return available, nil
+	}
+
+	byName := make(map[string]exportColumn, len(available))
+	names := make([]string, len(available))
+	for i, col := range available {
+		byName[col.name] = col
+		names[i] = col.name
+	}
+	selected := make([]exportColumn, 0, len(requested))
+	for _, name := range requested {
+		col, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, available columns: %s", name, strings.Join(names, ", "))
+		}
+		selected = append(selected, col)
+	}
+	return selected, nil
+}
+
+// exportValues returns the values of a record for the export columns.
+// Relationships become the reference IDs they link to, a list for to-many
+// relationships, or the related records themselves when embedding. Related
+// records the server did not include are embedded as identifiers.
+func exportValues(res *models.Resource, columns []exportColumn, relations string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		switch {
+		case col.name == "id":
+			values[i] = res.ID
+		case !col.relation:
+			values[i] = res.Attributes[col.name]
+		default:
+			rel, ok := res.Relationships[col.name]
+			if !ok || rel.Data == nil {
+				continue
+			}
+			var items []interface{}
+			for _, identifier := range rel.Identifiers() {
+				if relations != "embed" {
+					items = append(items, identifier.ID)
+					continue
+				}
+				var item interface{} = identifier
+				for _, related := range rel.Resources {
+					if related.Type == identifier.Type && related.ID == identifier.ID {
+						item = related.Embedded()
+						break
+					}
+				}
+				items = append(items, item)
+			}
+			switch {
+			case rel.IsToMany():
+				if items == nil {
+					items = []interface{}{}
+				}
+				values[i] = items
+			case len(items) > 0:
+				values[i] = items[0]
+			}
+		}
+	}
+	return values
+}
+
+func newExportWriter(format string, w io.Writer) exportWriter {
+	switch format {
+	case "ndjson":
+		return &jsonExportWriter{w: w}
+	case "json":
+		return &jsonExportWriter{w: w, array: true}
+	}
+	return &csvExportWriter{w: csv.NewWriter(w)}
+}
+
+// csvExportWriter writes a header line and a line per record. Lists of
+// reference IDs are joined with commas, which import reads back; other
+// lists and objects are written as JSON.
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvExportWriter) writeHeader(columns []exportColumn) error {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	return c.w.Write(header)
+}
+
+func (c *csvExportWriter) writeRecord(columns []exportColumn, values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		if ids, ok := value.([]interface{}); ok && columns[i].relation && allStrings(ids) {
+			parts := make([]string, len(ids))
+			for j, id := range ids {
+				parts[j] = id.(string)
+			}
+			record[i] = strings.Join(parts, ",")
+			continue
+		}
+		record[i] = plainValue(value)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvExportWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func allStrings(values []interface{}) bool {
+	for _, value := range values {
+		if _, ok := value.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonExportWriter writes a JSON object per record with its keys in column
+// order, one per line, or as the elements of a JSON array.
+type jsonExportWriter struct {
+	w       io.Writer
+	array   bool
+	written int
+}
+
+func (j *jsonExportWriter) writeHeader(columns []exportColumn) error {
+	if j.array {
+		_, err := io.WriteString(j.w, "[")
+		return err
+	}
+	return nil
+}
+
+func (j *jsonExportWriter) writeRecord(columns []exportColumn, values []interface{}) error {
+	var b bytes.Buffer
+	switch {
+	case j.array && j.written > 0:
+		b.WriteString(",\n  ")
+	case j.array:
+		b.WriteString("\n  ")
+	}
+	b.WriteString("{")
+	for i, col := range columns {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, _ := json.Marshal(col.name)
+		value, err := json.Marshal(values[i])
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", col.name, err)
+		}
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(value)
+	}
+	b.WriteString("}")
+	if !j.array {
+		b.WriteString("\n")
+	}
+	j.written++
+	_, err := j.w.Write(b.Bytes())
+	return err
+}
+
+func (j *jsonExportWriter) close() error {
+	if !j.array {
+		return nil
+	}
+	closing := "\n]\n"
+	if j.written == 0 {
+		closing = "]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
@@ -0,0 +1,330 @@
+// cmd/format.go
+
+package main
+
+import (
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// loadModel fetches the model of an entity to lay out its attributes. Tables
+// fall back to alphabetical columns and plain values when it is unavailable.
+func loadModel(client *api.Client, entityName string) *api.TableInfo {
+	model, err := client.GetEntityModel(entityName)
+	if err != nil {
+		utils.DebugLogger.Printf("Model of %s unavailable: %v", entityName, err)
+		return nil
+	}
+	return model
+}
+
+// parseColumnList splits a -columns flag value into column names.
+func parseColumnList(columns string) []string {
+	var names []string
+	for _, name := range strings.Split(columns, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// attributeColumns returns the attribute columns of resources in schema
+// order, followed by attributes missing from the schema in alphabetical
+// order. Standard and hidden columns are wide columns.
+func attributeColumns(resources []*models.Resource, model *api.TableInfo) []Column {
+	present := make(map[string]struct{})
+	for _, res := range resources {
+		for key := range res.Attributes {
+			present[key] = struct{}{}
+		}
+	}
+
+	var columns []Column
+	add := func(name string) {
+		if _, ok := present[name]; !ok {
+			return
+		}
+		delete(present, name)
+		columns = append(columns, Column{Name: name, Wide: isHiddenColumn(name, model)})
+	}
+
+	if model != nil {
+		for _, name := range model.ColumnOrder {
+			if model.ColumnModel[name].JsonApi == "" {
+				add(name)
+			}
+		}
+	}
+	rest := make([]string, 0, len(present))
+	for name := range present {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		add(name)
+	}
+	return columns
+}
+
+// isHiddenColumn reports whether a column is left out of narrow tables.
+func isHiddenColumn(name string, model *api.TableInfo) bool {
+	if _, ok := api.StandardColumns[name]; ok {
+		return true
+	}
+	if model != nil {
+		if col, ok := model.ColumnModel[name]; ok {
+			return col.ColumnType == "hidden" || col.ExcludeFromApi
+		}
+	}
+	return strings.HasPrefix(name, "__")
+}
+
+// columnInfo returns the schema of a column, or nil when it is unknown.
+func columnInfo(model *api.TableInfo, name string) *api.ColumnInfo {
+	if model == nil {
+		return nil
+	}
+	if col, ok := model.ColumnModel[name]; ok {
+		return &col
+	}
+	return nil
+}
+
+// formatCell formats an attribute value for a table cell according to the
+// type of its column. Machine-readable formats only get plain values.
+func formatCell(value interface{}, col *api.ColumnInfo) string {
+	if value == nil {
+		return ""
+	}
+	if !isTableOutput() || col == nil {
+		return plainValue(value)
+	}
+
+	for _, option := range col.Options {
+		if option.Label != "" && plainValue(option.Value) == plainValue(value) {
+			return option.Label
+		}
+	}
+
+	switch {
+	case col.ColumnType == "datetime" || col.ColumnType == "timestamp":
+		return formatTimestamp(value, "2006-01-02 15:04:05")
+	case col.ColumnType == "date":
+		return formatTimestamp(value, "2006-01-02")
+	case col.ColumnType == "truefalse":
+		return strconv.FormatBool(api.Truthy(value))
+	case col.ColumnType == "json":
+		return truncateWidth(compactJSON(value), 40)
+	case strings.HasPrefix(col.ColumnType, "file"):
+		return formatFiles(value)
+	}
+	return singleLine(plainValue(value))
+}
+
+// plainValue formats a decoded JSON value without exponents for numbers and
+// as compact JSON for objects and lists.
+func plainValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		return compactJSON(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func compactJSON(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return singleLine(s)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+func formatTimestamp(value interface{}, layout string) string {
+	formatted := formatDate(layout, value)
+	if t, ok := value.(string); ok && formatted == t {
+		return singleLine(t)
+	}
+	return formatted
+}
+
+// formatFiles describes the files held by a file column as "name (size)".
+func formatFiles(value interface{}) string {
+	files, ok := value.([]interface{})
+	if !ok {
+		return singleLine(plainValue(value))
+	}
+	var names []string
+	for _, item := range files {
+		file, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := file["name"].(string)
+		if name == "" {
+			name, _ = file["path"].(string)
+		}
+		if size, ok := fileSize(file); ok {
+			name = fmt.Sprintf("%s (%s)", name, humanSize(size))
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// fileSize returns the size reported for a file, or the decoded size of its
+// base64 contents.
+func fileSize(file map[string]interface{}) (int64, bool) {
+	if size, ok := file["size"].(float64); ok {
+		return int64(size), true
+	}
+	for _, key := range []string{"contents", "file"} {
+		if contents, ok := file[key].(string); ok && contents != "" {
+			if i := strings.Index(contents, ","); i >= 0 && strings.HasPrefix(contents, "data:") {
+				contents = contents[i+1:]
+			}
+			return int64(len(contents)) * 3 / 4, true
+		}
+	}
+	return 0, false
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// singleLine replaces line breaks so that a value fits on one table row.
+func singleLine(s string) string {
+	if !strings.ContainsAny(s, "\r\n\t") {
+		return s
+	}
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ").Replace(s)
+}
+
+// runeWidth returns the number of terminal cells a rune occupies: zero for
+// combining marks and control characters, two for wide East Asian
+// characters and emoji, one otherwise.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r):
+		return 0
+	case r < 32 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r >= 0x1100 && (r <= 0x115f || // Hangul Jamo
+		r == 0x2329 || r == 0x232a ||
+		(r >= 0x2e80 && r <= 0xa4cf && r != 0x303f) || // CJK to Yi
+		(r >= 0xac00 && r <= 0xd7a3) || // Hangul syllables
+		(r >= 0xf900 && r <= 0xfaff) || // CJK compatibility ideographs
+		(r >= 0xfe30 && r <= 0xfe4f) || // CJK compatibility forms
+		(r >= 0xff00 && r <= 0xff60) || // Fullwidth forms
+		(r >= 0xffe0 && r <= 0xffe6) ||
+		(r >= 0x1f300 && r <= 0x1f64f) || // Emoji
+		(r >= 0x1f900 && r <= 0x1f9ff) ||
+		(r >= 0x20000 && r <= 0x3fffd)):
+		return 2
+	}
+	return 1
+}
+
+// displayWidth returns the number of terminal cells s occupies.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// truncateWidth shortens s to at most width terminal cells, never splitting
+// a character, and marks the cut with "…".
+func truncateWidth(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	var b strings.Builder
+	used := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		w := runeWidth(r)
+		if used+w > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+		s = s[size:]
+	}
+	b.WriteString("…")
+	return b.String()
+}
+
+// wrapText splits s into lines of at most width terminal cells, breaking at
+// spaces where possible.
+func wrapText(s string, width int) []string {
+	if width < 1 || displayWidth(s) <= width {
+		return []string{s}
+	}
+	var lines []string
+	for displayWidth(s) > width {
+		cut, used, space := 0, 0, -1
+		for i, r := range s {
+			w := runeWidth(r)
+			if used+w > width {
+				break
+			}
+			used += w
+			cut = i + utf8.RuneLen(r)
+			if r == ' ' {
+				space = i
+			}
+		}
+		switch {
+		case space > 0:
+			lines = append(lines, strings.TrimRight(s[:space], " "))
+			s = strings.TrimLeft(s[space+1:], " ")
+		case cut == 0:
+			_, size := utf8.DecodeRuneInString(s)
+			lines = append(lines, s[:size])
+			s = s[size:]
+		default:
+			lines = append(lines, s[:cut])
+			s = s[cut:]
+		}
+	}
+	if s != "" {
+		lines = append(lines, s)
+	}
+	return lines
+}
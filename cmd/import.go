@@ -0,0 +1,646 @@
+// cmd/import.go
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// importRow is one record of an import file, with its values by input
+// column.
+type importRow struct {
+	number int // Position in the file, from 1
+	fields map[string]interface{}
+}
+
+// importReject is a record the import could not load, as written to the
+// rejects file.
+type importReject struct {
+	Row    int                    `json:"row"`
+	Record map[string]interface{} `json:"record"`
+	Error  string                 `json:"error"`
+	Status int                    `json:"status,omitempty"`
+	Errors interface{}            `json:"errors,omitempty"` // JSON:API errors of the response
+}
+
+// importCheckpoint records the rows of an import that are done, loaded or
+// rejected, so that --resume can skip them. The digest of the file ties the
+// row numbers to its contents.
+type importCheckpoint struct {
+	Type      string `json:"type"`
+	File      string `json:"file"`
+	SHA256    string `json:"sha256"`
+	Done      int    `json:"done"`                // Every row up to this one is done
+	Completed []int  `json:"completed,omitempty"` // Rows after Done that are done too
+}
+
+// importer turns rows into resources and sends them.
+type importer struct {
+	client       *api.Client
+	resourceType string
+	model        *api.TableInfo
+	mapping      map[string]string // Entity column by input column; "" skips the column
+	key          []string          // Identity columns for upserts
+	csv          bool
+	noValidate   bool
+}
+
+func importCommand(client *api.Client, args []string) {
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	registerGlobalFlags(importCmd)
+	resourceType := importCmd.String("type", "", "Resource type to import into")
+	path := importCmd.String("f", "", "CSV, NDJSON or JSON file of records, '-' for stdin")
+	format := importCmd.String("format", "", "Input format: csv or json, which covers NDJSON (default: from the file extension)")
+	mappingPath := importCmd.String("map", "", "JSON or YAML file mapping input columns to entity columns; map a column to null to skip it")
+	key := importCmd.String("key", "", "Comma-separated identity columns; records matching an existing one update it instead")
+	concurrency := importCmd.Int("concurrency", 8, "Number of records to send at the same time")
+	rejectsPath := importCmd.String("rejects", "", "File to write rejected records and their errors to (default: <file>.rejects.ndjson)")
+	resume := importCmd.Bool("resume", false, "Skip the records an interrupted import of the same file already processed")
+	noValidate := importCmd.Bool("no-validate", false, "Send the records without checking them against the entity model")
+	importCmd.Parse(args)
+
+	if *resourceType == "" || *path == "" {
+		importCmd.Usage()
+		os.Exit(1)
+	}
+	if *resume && *path == "-" {
+		utils.ErrorLogger.Println("--resume needs a file; stdin cannot be read again")
+		os.Exit(1)
+	}
+	if *rejectsPath == "" {
+		*rejectsPath = strings.TrimSuffix(inputName(*path), filepath.Ext(*path)) + ".rejects.ndjson"
+	}
+	checkpointPath := ""
+	if *path != "-" && !globalOptions.dryRun {
+		checkpointPath = *path + ".checkpoint"
+	}
+	if globalOptions.dryRun {
+		// Print the requests one after the other
+		*concurrency = 1
+	}
+
+	data, err := readInput(*path)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to read input:", err)
+		os.Exit(1)
+	}
+	isCSV := importFormat(*path, *format, data) == "csv"
+	var rows []importRow
+	var header []string
+	if isCSV {
+		header, rows, err = readCSVRows(data)
+	} else {
+		rows, err = readJSONRows(data, filepath.Ext(*path))
+	}
+	if err != nil {
+		utils.ErrorLogger.Printf("Failed to parse %s: %v", inputName(*path), err)
+		os.Exit(1)
+	}
+
+	imp := &importer{
+		client:       client,
+		resourceType: *resourceType,
+		key:          parseColumnList(*key),
+		csv:          isCSV,
+		noValidate:   *noValidate,
+	}
+	if *mappingPath != "" {
+		if imp.mapping, err = readColumnMapping(*mappingPath); err != nil {
+			utils.ErrorLogger.Println("Failed to read column mapping:", err)
+			os.Exit(1)
+		}
+	}
+	if imp.model, err = client.GetEntityModel(*resourceType); err != nil {
+		utils.ErrorLogger.Printf("Failed to fetch the model of %s: %v", *resourceType, err)
+		os.Exit(1)
+	}
+	if imp.model.TableName == "" {
+		imp.model.TableName = *resourceType
+	}
+	if err := imp.checkColumns(header); err != nil {
+		utils.ErrorLogger.Println("Invalid columns:", err)
+		os.Exit(1)
+	}
+
+	checkpoint := &importCheckpoint{Type: *resourceType, File: *path, SHA256: fmt.Sprintf("%x", sha256.Sum256(data))}
+	if *resume {
+		if checkpoint, err = readCheckpoint(checkpointPath, checkpoint); err != nil {
+			utils.ErrorLogger.Println("Failed to resume:", err)
+			os.Exit(1)
+		}
+	}
+	tracker := newImportTracker(checkpoint, checkpointPath)
+	var pending []importRow
+	for _, row := range rows {
+		if !tracker.isDone(row.number) {
+			pending = append(pending, row)
+		}
+	}
+
+	rejects := &ndjsonFile{path: *rejectsPath, appending: *resume}
+	defer rejects.close()
+
+	// Stop sending on interrupt, letting the records in flight finish so
+	// that the checkpoint is accurate
+	var stopped atomic.Bool
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		stopped.Store(true)
+	}()
+
+	var created, updated atomic.Int64
+	var fatal error
+	var fatalOnce sync.Once
+	bar := startProgress(len(pending), stderrIsTerminal() && !globalOptions.dryRun)
+	forEachConcurrently(len(pending), *concurrency, func(i int) {
+		if stopped.Load() {
+			return
+		}
+		row := pending[i]
+		outcome, err := imp.load(row)
+
+		// Without an answer from the server the record may or may not have
+		// been loaded, so stop and leave it to --resume
+		var transportError *url.Error
+		if errors.As(err, &transportError) {
+			fatalOnce.Do(func() { fatal = fmt.Errorf("row %d: %w", row.number, err) })
+			stopped.Store(true)
+			return
+		}
+
+		switch {
+		case err != nil:
+			if werr := rejects.write(newImportReject(row, err)); werr != nil {
+				fatalOnce.Do(func() { fatal = fmt.Errorf("failed to write rejects: %w", werr) })
+				stopped.Store(true)
+				return
+			}
+			bar.fail()
+		case outcome == "updated":
+			updated.Add(1)
+			bar.advance()
+		default:
+			created.Add(1)
+			bar.advance()
+		}
+		tracker.done(row.number)
+	})
+	elapsed := bar.stop()
+	signal.Stop(interrupts)
+
+	summary := map[string]interface{}{
+		"type":     *resourceType,
+		"created":  created.Load(),
+		"updated":  updated.Load(),
+		"rejected": rejects.count,
+		"skipped":  len(rows) - len(pending),
+		"seconds":  elapsed.Seconds(),
+	}
+	if rejects.count > 0 {
+		summary["rejects"] = *rejectsPath
+	}
+	message := fmt.Sprintf("%d created, %d updated, %d rejected in %s (%.1f records/s).",
+		created.Load(), updated.Load(), rejects.count, elapsed.Round(time.Millisecond), throughput(bar.processed(), elapsed))
+	if skipped := len(rows) - len(pending); skipped > 0 {
+		message += fmt.Sprintf(" %d skipped as already imported.", skipped)
+	}
+	if globalOptions.dryRun {
+		message = "Dry run, nothing sent: " + message
+	}
+
+	if fatal != nil || stopped.Load() {
+		if err := tracker.save(); err != nil {
+			utils.ErrorLogger.Println("Failed to save checkpoint:", err)
+		}
+		render(&View{Message: message, Data: summary})
+		if fatal != nil {
+			utils.ErrorLogger.Println("Import stopped:", fatal)
+		} else {
+			utils.ErrorLogger.Println("Import interrupted.")
+		}
+		if checkpointPath != "" {
+			utils.ErrorLogger.Println("Run the same command with --resume to continue.")
+		}
+		os.Exit(1)
+	}
+
+	if checkpointPath != "" {
+		os.Remove(checkpointPath)
+	}
+	render(&View{Message: message, Data: summary})
+	if rejects.count > 0 {
+		utils.ErrorLogger.Printf("%d records were rejected; see %s", rejects.count, *rejectsPath)
+		os.Exit(1)
+	}
+}
+
+// importFormat returns "csv" or "json" for the input, from the -format flag,
+// the file extension or, for stdin, the first character of the data.
+func importFormat(path, format string, data []byte) string {
+	switch strings.ToLower(format) {
+	case "csv":
+		return "csv"
+	case "json", "ndjson", "jsonl":
+		return "json"
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".json", ".ndjson", ".jsonl":
+		return "json"
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return "json"
+	}
+	return "csv"
+}
+
+// readCSVRows reads a CSV file whose first line names the columns.
+func readCSVRows(data []byte) ([]string, []importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("no header line")
+	}
+	header := records[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	rows := make([]importRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		fields := make(map[string]interface{}, len(header))
+		for j, column := range header {
+			fields[column] = record[j]
+		}
+		rows = append(rows, importRow{number: i + 1, fields: fields})
+	}
+	return header, rows, nil
+}
+
+// readJSONRows reads the records of NDJSON, a JSON array or JSON:API
+// documents, like -f of create does.
+func readJSONRows(data []byte, ext string) ([]importRow, error) {
+	documents, err := decodeDocuments(data, ext)
+	if err != nil {
+		return nil, err
+	}
+	var rows []importRow
+	for i, document := range documents {
+		resources, err := resourcesFromDocument(document)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		for _, res := range resources {
+			rows = append(rows, importRow{number: len(rows) + 1, fields: res.Attributes})
+		}
+	}
+	return rows, nil
+}
+
+// readColumnMapping reads a JSON or YAML object mapping input columns to
+// entity columns. Columns mapped to null or "" are skipped.
+func readColumnMapping(path string) (map[string]string, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := decodeDocuments(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", inputName(path), err)
+	}
+	if len(documents) != 1 {
+		return nil, fmt.Errorf("%s should hold one object, found %d documents", inputName(path), len(documents))
+	}
+	obj, ok := documents[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s should hold an object of input column: entity column", inputName(path))
+	}
+	mapping := make(map[string]string, len(obj))
+	for source, target := range obj {
+		switch target := target.(type) {
+		case nil:
+			mapping[source] = ""
+		case string:
+			mapping[source] = target
+		default:
+			return nil, fmt.Errorf("column %s is mapped to %v, expected a column name or null", source, target)
+		}
+	}
+	return mapping, nil
+}
+
+// column returns the entity column an input column goes to, or "" when it
+// is skipped.
+func (imp *importer) column(source string) string {
+	if target, ok := imp.mapping[source]; ok {
+		return target
+	}
+	return source
+}
+
+// checkColumns fails when a CSV header names a column the entity does not
+// have, before any record is sent.
+func (imp *importer) checkColumns(header []string) error {
+	var unknown []string
+	for _, source := range header {
+		column := imp.column(source)
+		if column == "" {
+			continue
+		}
+		if _, ok := imp.model.ColumnModel[column]; !ok {
+			unknown = append(unknown, source)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%s of the input are not columns of %s; map or skip them with -map (columns: %s)",
+			strings.Join(unknown, ", "), imp.resourceType, strings.Join(attributeNames(imp.model), ", "))
+	}
+	return nil
+}
+
+// resource turns a row into a resource. Text values are coerced to the
+// types of their columns, and values of relation columns are taken as the
+// reference IDs to link, comma-separated for hasMany relations. Empty CSV
+// cells are left out.
+func (imp *importer) resource(row importRow) (*models.Resource, error) {
+	res := &models.Resource{Type: imp.resourceType, Attributes: make(map[string]interface{})}
+	for _, source := range sortedKeys(row.fields) {
+		column := imp.column(source)
+		value := row.fields[source]
+		text, isText := value.(string)
+		if column == "" || (imp.csv && text == "") {
+			continue
+		}
+
+		col, known := imp.model.ColumnModel[column]
+		switch {
+		case known && col.JsonApi != "":
+			if !isText {
+				return nil, fmt.Errorf("%s should hold reference IDs, got %v", column, value)
+			}
+			if res.Relationships == nil {
+				res.Relationships = make(map[string]models.Relationship)
+			}
+			res.Relationships[column] = models.Relationship{Data: referenceLinkage(text, col)}
+			continue
+		case known && isText:
+			coerced, err := coerceValue(text, col)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s (%s): %w", column, columnTypeName(col), err)
+			}
+			value = coerced
+		}
+		res.Attributes[column] = value
+	}
+	return res, nil
+}
+
+// referenceLinkage returns the linkage to the records a relation column
+// refers to by reference ID.
+func referenceLinkage(text string, col api.ColumnInfo) interface{} {
+	if col.JsonApi != "hasMany" {
+		return models.ResourceIdentifier{Type: col.Type, ID: strings.TrimSpace(text)}
+	}
+	var linkage []models.ResourceIdentifier
+	for _, id := range strings.Split(text, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			linkage = append(linkage, models.ResourceIdentifier{Type: col.Type, ID: id})
+		}
+	}
+	return linkage
+}
+
+// load creates the record of a row or, with identity columns, updates the
+// record it matches. It returns "created" or "updated".
+func (imp *importer) load(row importRow) (string, error) {
+	res, err := imp.resource(row)
+	if err != nil {
+		return "", err
+	}
+
+	var existing *models.Resource
+	if len(imp.key) > 0 {
+		if existing, _, err = findExisting(imp.client, res, imp.key); err != nil {
+			return "", err
+		}
+	}
+	if !imp.noValidate {
+		if err := imp.model.Validate(res.Attributes, existing == nil); err != nil {
+			return "", err
+		}
+	}
+
+	if existing != nil {
+		res.ID = existing.ID
+		_, err = updateLinked(imp.client, res)
+		if isDryRun(err) {
+			err = nil
+		}
+		return "updated", err
+	}
+	_, err = createLinked(imp.client, res)
+	if isDryRun(err) {
+		err = nil
+	}
+	return "created", err
+}
+
+// newImportReject describes a rejected row with the error and, for errors
+// of the server, its status and error objects.
+func newImportReject(row importRow, err error) importReject {
+	reject := importReject{Row: row.number, Record: row.fields, Error: err.Error()}
+	var apiError *api.APIError
+	if errors.As(err, &apiError) {
+		reject.Status = apiError.StatusCode
+		if len(apiError.Errors) > 0 {
+			reject.Errors = apiError.Errors
+		}
+	}
+	return reject
+}
+
+// readCheckpoint reads the checkpoint an interrupted import left behind. It
+// fails unless the checkpoint is one of an import of the same contents into
+// the same type as current.
+func readCheckpoint(path string, current *importCheckpoint) (*importCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no checkpoint found at %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint importCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	if checkpoint.Type != current.Type {
+		return nil, fmt.Errorf("%s is a checkpoint of an import into %s, not %s", path, checkpoint.Type, current.Type)
+	}
+	if checkpoint.SHA256 != current.SHA256 {
+		return nil, fmt.Errorf("%s changed since the interrupted import, so the rows in %s no longer match it; remove the checkpoint to import the file from the start", current.File, path)
+	}
+	return &checkpoint, nil
+}
+
+// importTracker keeps the checkpoint up to date as rows finish in any order,
+// saving it at most once a second.
+type importTracker struct {
+	path string
+
+	mu         sync.Mutex
+	checkpoint *importCheckpoint
+	completed  map[int]bool
+	saved      time.Time
+}
+
+func newImportTracker(checkpoint *importCheckpoint, path string) *importTracker {
+	t := &importTracker{path: path, checkpoint: checkpoint, completed: make(map[int]bool), saved: time.Now()}
+	for _, row := range checkpoint.Completed {
+		t.completed[row] = true
+	}
+	return t
+}
+
+func (t *importTracker) isDone(row int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return row <= t.checkpoint.Done || t.completed[row]
+}
+
+func (t *importTracker) done(row int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.completed[row] = true
+	for t.completed[t.checkpoint.Done+1] {
+		delete(t.completed, t.checkpoint.Done+1)
+		t.checkpoint.Done++
+	}
+	if time.Since(t.saved) >= time.Second {
+		if err := t.saveLocked(); err != nil {
+			utils.DebugLogger.Printf("Failed to save checkpoint: %v", err)
+		}
+	}
+}
+
+func (t *importTracker) save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.saveLocked()
+}
+
+// saveLocked writes the checkpoint through a temporary file, so that an
+// interrupted write leaves the previous checkpoint intact.
+func (t *importTracker) saveLocked() error {
+	t.saved = time.Now()
+	if t.path == "" {
+		return nil
+	}
+	t.checkpoint.Completed = t.checkpoint.Completed[:0]
+	for row := range t.completed {
+		t.checkpoint.Completed = append(t.checkpoint.Completed, row)
+	}
+	sort.Ints(t.checkpoint.Completed)
+	data, err := json.MarshalIndent(t.checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(t.path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(t.path+".tmp", t.path)
+}
+
+// progressBar draws the progress of a bulk operation on stderr.
+type progressBar struct {
+	total  int
+	start  time.Time
+	ok     atomic.Int64
+	failed atomic.Int64
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// startProgress starts redrawing a progress bar for total items until stop
+// is called, or only counts the items when show is false. A total of 0
+// means it is unknown.
+func startProgress(total int, show bool) *progressBar {
+	p := &progressBar{total: total, start: time.Now(), quit: make(chan struct{})}
+	if !show {
+		return p
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			p.draw()
+			select {
+			case <-ticker.C:
+			case <-p.quit:
+				p.draw()
+				fmt.Fprintln(os.Stderr)
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *progressBar) advance()       { p.ok.Add(1) }
+func (p *progressBar) fail()          { p.failed.Add(1) }
+func (p *progressBar) processed() int { return int(p.ok.Load() + p.failed.Load()) }
+
+// stop stops redrawing and returns the time since the start.
+func (p *progressBar) stop() time.Duration {
+	close(p.quit)
+	p.wg.Wait()
+	return time.Since(p.start)
+}
+
+func (p *progressBar) draw() {
+	const width = 30
+	done := p.processed()
+	rate := throughput(done, time.Since(p.start))
+	if p.total == 0 {
+		fmt.Fprintf(os.Stderr, "\r%d done  %.1f/s  %d failed ", done, rate, p.failed.Load())
+		return
+	}
+	filled := done * width / p.total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	fmt.Fprintf(os.Stderr, "\r[%s] %d/%d %3d%%  %.1f/s  %d failed ",
+		bar, done, p.total, done*100/p.total, rate, p.failed.Load())
+}
+
+// throughput returns the number of items per second.
+func throughput(count int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed.Seconds()
+}
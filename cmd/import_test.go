@@ -0,0 +1,81 @@
+// cmd/import_test.go
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// An import stopped by a lost connection is resumed from its checkpoint,
+// but not after the file changed.
+func TestImportResume(t *testing.T) {
+	f := newFakeServer(t)
+	f.models["user_account"] = userAccountModel
+	// The connection is lost while the second record is sent, once
+	posts, dropped := 0, false
+	f.drop = func(r *http.Request) bool {
+		if r.Method != http.MethodPost {
+			return false
+		}
+		if posts++; posts == 2 && !dropped {
+			dropped = true
+			return true
+		}
+		return false
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "users.ndjson")
+	rows := "{\"name\":\"Al\"}\n{\"name\":\"Bo\"}\n{\"name\":\"Cy\"}\n"
+	if err := os.WriteFile(path, []byte(rows), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"import", "-type", "user_account", "-f", path, "-concurrency", "1"}
+
+	_, stderr, code := f.run("", args...)
+	if code != 1 || !strings.Contains(stderr, "--resume") {
+		t.Fatalf("import with a lost connection exited with %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(path + ".checkpoint"); err != nil {
+		t.Fatalf("no checkpoint after the lost connection: %v", err)
+	}
+
+	// Rows may have moved, so a changed file is not resumed
+	if err := os.WriteFile(path, []byte("{\"name\":\"Zed\"}\n"+rows), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	posts = 0
+	_, stderr, code = f.run("", append(args, "--resume")...)
+	if code != 1 || !strings.Contains(stderr, "changed since the interrupted import") {
+		t.Errorf("resume of a changed file exited with %d: %s", code, stderr)
+	}
+	if posts != 0 {
+		t.Errorf("resume of a changed file sent %d records", posts)
+	}
+	if _, err := os.Stat(path + ".checkpoint"); err != nil {
+		t.Errorf("the refused resume removed the checkpoint: %v", err)
+	}
+
+	// With the file as it was, the rows after the first are sent again
+	if err := os.WriteFile(path, []byte(rows), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code := f.run("", append(args, "--resume", "-o", "json")...)
+	if code != 0 || posts != 2 || !strings.Contains(stdout, `"skipped": 1`) {
+		t.Errorf("resume exited with %d after sending %d records: %s%s", code, posts, stdout, stderr)
+	}
+	if _, err := os.Stat(path + ".checkpoint"); !os.IsNotExist(err) {
+		t.Errorf("the checkpoint is left after the import finished: %v", err)
+	}
+	var names []string
+	for _, record := range f.records["user_account"] {
+		names = append(names, record.Attributes["name"].(string))
+	}
+	if len(names) != 3 {
+		t.Errorf("imported %v, want Al, Bo and Cy", names)
+	}
+}
@@ -0,0 +1,541 @@
+// cmd/input.go
+
+package main
+
+import (
+	"bytes"
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// attributeFlags holds the options giving the attributes of the resources
+// to create or update.
+type attributeFlags struct {
+	attributes *string
+	file       *string
+	sets       stringList
+	setFiles   stringList
+	rels       stringList
+	noValidate *bool
+}
+
+func registerAttributeFlags(fs *flag.FlagSet) *attributeFlags {
+	f := &attributeFlags{
+		attributes: fs.String("attributes", "", "Resource attributes in JSON format"),
+		file:       fs.String("f", "", "JSON, YAML or NDJSON file with attributes or JSON:API documents, '-' for stdin"),
+	}
+	fs.Var(&f.sets, "set", "Set an attribute, as column=value or column:=json (repeatable)")
+	fs.Var(&f.setFiles, "set-file", "Set an attribute to the contents of a file, as column=@path (repeatable)")
+	fs.Var(&f.rels, "rel", "Link a relationship, as relation=type:id, or relation=type:id1,id2 for to-many relations (repeatable)")
+	f.noValidate = fs.Bool("no-validate", false, "Send the attributes without checking them against the entity model")
+	return f
+}
+
+// validate checks the attributes of resources against their entity models,
+// unless --no-validate is given. Resources whose model is unavailable are
+// left to the server.
+func (f *attributeFlags) validate(client *api.Client, resources []*models.Resource, create bool) error {
+	if *f.noValidate {
+		return nil
+	}
+	return validateResources(client, resources, create)
+}
+
+// validateResources checks the attributes of resources against their entity
+// models and reports the violations of every resource together.
+func validateResources(client *api.Client, resources []*models.Resource, create bool) error {
+	modelsByType := make(map[string]*api.TableInfo)
+	var failures []string
+	for i, res := range resources {
+		model, ok := modelsByType[res.Type]
+		if !ok {
+			model = loadModel(client, res.Type)
+			modelsByType[res.Type] = model
+		}
+		if model == nil {
+			continue
+		}
+		if err := model.Validate(res.Attributes, create); err != nil {
+			if len(resources) == 1 {
+				return err
+			}
+			failures = append(failures, fmt.Sprintf("item %d: %v", i+1, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d items are invalid:\n%s", len(failures), len(resources), strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// empty reports whether no attributes were given at all.
+func (f *attributeFlags) empty() bool {
+	return *f.attributes == "" && *f.file == "" && len(f.sets) == 0 && len(f.setFiles) == 0 && len(f.rels) == 0
+}
+
+// resources returns the resources described by the flags. Resources without
+// a type or ID get resourceType and id; when id is set, there must be exactly
+// one resource. Attributes given with --set and --set-file are coerced to the
+// types of their columns and applied to every resource, as are relationships
+// given with --rel.
+func (f *attributeFlags) resources(client *api.Client, resourceType, id string) ([]*models.Resource, error) {
+	assignments, err := parseAssignments(f.sets, f.setFiles)
+	if err != nil {
+		return nil, err
+	}
+	relations, err := parseRelationAssignments(f.rels)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*models.Resource
+	switch {
+	case *f.attributes != "" && *f.file != "":
+		return nil, fmt.Errorf("-attributes and -f cannot be used together")
+	case *f.attributes == "" && *f.file == "" && (len(assignments) > 0 || len(relations) > 0):
+		resources = []*models.Resource{{Attributes: map[string]interface{}{}}}
+	case *f.attributes != "":
+		var attrs map[string]interface{}
+		if err := json.Unmarshal([]byte(*f.attributes), &attrs); err != nil {
+			return nil, fmt.Errorf("invalid attributes JSON: %w", err)
+		}
+		resources = []*models.Resource{{Attributes: attrs}}
+	case *f.file != "":
+		var err error
+		if resources, err = readResourceFile(*f.file); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("one of -attributes, -f, --set or --rel is required")
+	}
+
+	if id != "" && len(resources) != 1 {
+		return nil, fmt.Errorf("-id given but the input holds %d resources", len(resources))
+	}
+	for i, res := range resources {
+		switch {
+		case res.Type == "":
+			res.Type = resourceType
+		case resourceType != "" && res.Type != resourceType:
+			return nil, fmt.Errorf("item %d is of type %q, not %q", i+1, res.Type, resourceType)
+		}
+		if res.Type == "" {
+			return nil, fmt.Errorf("item %d has no type; pass -type", i+1)
+		}
+		if id != "" {
+			res.ID = id
+		}
+	}
+
+	if len(assignments) > 0 || len(relations) > 0 {
+		if err := applyAssignments(client, resources, assignments, relations); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// applyAssignments sets the attributes given with --set and --set-file and
+// the relationships given with --rel on every resource, using the model of
+// each resource type for coercion and checks.
+func applyAssignments(client *api.Client, resources []*models.Resource, assignments []assignment, relations []relationAssignment) error {
+	values := make(map[string]map[string]interface{})
+	links := make(map[string]map[string]models.Relationship)
+	for _, res := range resources {
+		attrs, ok := values[res.Type]
+		if !ok {
+			model, err := client.GetEntityModel(res.Type)
+			if err != nil {
+				return fmt.Errorf("failed to fetch the model of %s to check --set and --rel columns: %w", res.Type, err)
+			}
+			if model.TableName == "" {
+				model.TableName = res.Type
+			}
+			attrs = make(map[string]interface{}, len(assignments))
+			for _, a := range assignments {
+				value, err := assignmentValue(a, model)
+				if err != nil {
+					return err
+				}
+				attrs[a.column] = value
+			}
+			values[res.Type] = attrs
+
+			links[res.Type] = make(map[string]models.Relationship, len(relations))
+			for _, a := range relations {
+				rel, err := relationshipValue(a, model)
+				if err != nil {
+					return err
+				}
+				links[res.Type][a.relation] = rel
+			}
+		}
+
+		if res.Attributes == nil {
+			res.Attributes = make(map[string]interface{}, len(attrs))
+		}
+		for column, value := range attrs {
+			res.Attributes[column] = value
+		}
+		if len(links[res.Type]) > 0 && res.Relationships == nil {
+			res.Relationships = make(map[string]models.Relationship, len(links[res.Type]))
+		}
+		for name, rel := range links[res.Type] {
+			res.Relationships[name] = rel
+		}
+	}
+	return nil
+}
+
+// readResourceFile reads resources from a file, or from stdin when path is
+// "-". The file holds one or more JSON or YAML documents: a YAML stream,
+// NDJSON, a JSON array, or a single document. Every document is either the
+// bare attributes of a resource or a JSON:API document whose data is one
+// resource or a list of them.
+func readResourceFile(path string) ([]*models.Resource, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := decodeDocuments(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", inputName(path), err)
+	}
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("%s holds no documents", inputName(path))
+	}
+
+	var resources []*models.Resource
+	for i, document := range documents {
+		items, err := resourcesFromDocument(document)
+		if err != nil {
+			return nil, fmt.Errorf("document %d of %s: %w", i+1, inputName(path), err)
+		}
+		resources = append(resources, items...)
+	}
+	return resources, nil
+}
+
+func inputName(path string) string {
+	if path == "-" {
+		return "stdin"
+	}
+	return path
+}
+
+// decodeDocuments decodes a stream of JSON documents, or of YAML documents
+// when the extension says so or the input is not JSON. YAML values are
+// converted to the types JSON decoding produces. Top-level arrays are
+// flattened into their elements.
+func decodeDocuments(data []byte, ext string) ([]interface{}, error) {
+	var documents []interface{}
+	var err error
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		documents, err = decodeYAMLDocuments(data)
+	case ".json", ".ndjson", ".jsonl":
+		documents, err = decodeJSONDocuments(data)
+	default:
+		if documents, err = decodeJSONDocuments(data); err != nil {
+			documents, err = decodeYAMLDocuments(data)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var flat []interface{}
+	for _, document := range documents {
+		if list, ok := document.([]interface{}); ok {
+			flat = append(flat, list...)
+		} else {
+			flat = append(flat, document)
+		}
+	}
+	return flat, nil
+}
+
+func decodeJSONDocuments(data []byte) ([]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var documents []interface{}
+	for {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+func decodeYAMLDocuments(data []byte) ([]interface{}, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var documents []interface{}
+	for {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if document == nil {
+			continue
+		}
+		encoded, err := json.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		var converted interface{}
+		if err := json.Unmarshal(encoded, &converted); err != nil {
+			return nil, err
+		}
+		documents = append(documents, converted)
+	}
+}
+
+// resourcesFromDocument returns the resources of a JSON:API document or a
+// bare resource object with type and attributes members, or a resource with
+// the document as attributes otherwise.
+func resourcesFromDocument(document interface{}) ([]*models.Resource, error) {
+	obj, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object, got %T", document)
+	}
+	data, ok := obj["data"]
+	if !ok {
+		_, typed := obj["type"].(string)
+		_, hasAttributes := obj["attributes"].(map[string]interface{})
+		if !typed || !hasAttributes {
+			return []*models.Resource{{Attributes: obj}}, nil
+		}
+		data = obj
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, isList := data.([]interface{}); isList {
+		var resources []*models.Resource
+		if err := json.Unmarshal(encoded, &resources); err != nil {
+			return nil, fmt.Errorf("invalid resource list: %w", err)
+		}
+		return resources, nil
+	}
+	var resource models.Resource
+	if err := json.Unmarshal(encoded, &resource); err != nil {
+		return nil, fmt.Errorf("invalid resource: %w", err)
+	}
+	return []*models.Resource{&resource}, nil
+}
+
+// batchResult is the outcome of one item of a multi-resource operation.
+type batchResult struct {
+	Item  int    `json:"item"`
+	Type  string `json:"type"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// batchView summarises the results of a multi-resource operation, one row
+// per item.
+func batchView(results []batchResult, verb string) *View {
+	view := &View{
+		Columns: columnNames("Item", "Type", "ID", "Result"),
+		Data:    results,
+	}
+	failed := 0
+	for _, result := range results {
+		outcome := verb
+		if result.Error != "" {
+			outcome = "failed: " + result.Error
+			failed++
+		}
+		view.Rows = append(view.Rows, []string{fmt.Sprint(result.Item), result.Type, result.ID, outcome})
+	}
+	view.Notes = []string{fmt.Sprintf("%d %s, %d failed.", len(results)-failed, verb, failed)}
+	return view
+}
+
+// batchFailed reports whether any item of a multi-resource operation failed.
+func batchFailed(results []batchResult) bool {
+	for _, result := range results {
+		if result.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// createLinked creates a resource together with its relationships. When the
+// server rejects relationships sent inline, the resource is created without
+// them and they are set through the relationship endpoints.
+func createLinked(client *api.Client, res *models.Resource) (*models.Resource, error) {
+	created, rejected := client.Create(res)
+	if !relationshipsRejected(rejected, res.Relationships) {
+		return created, rejected
+	}
+	utils.DebugLogger.Printf("Inline relationships of %s rejected, setting them separately: %v", res.Type, rejected)
+	bare := *res
+	bare.Relationships = nil
+	created, err := client.Create(&bare)
+	if err != nil {
+		return nil, retryError(rejected, err)
+	}
+	if err := setRelationships(client, created.Type, created.ID, res.Relationships, true); err != nil {
+		return created, fmt.Errorf("created %s %s, but %w", created.Type, created.ID, err)
+	}
+	return created, nil
+}
+
+// updateLinked updates a resource together with its relationships, falling
+// back to the relationship endpoints like createLinked.
+func updateLinked(client *api.Client, res *models.Resource) (*models.Resource, error) {
+	return updateLinkedIfVersion(client, res, -1)
+}
+
+// updateLinkedIfVersion is updateLinked for a resource that must still be at
+// the expected version, unless that is negative. The version is checked
+// before the first request.
+func updateLinkedIfVersion(client *api.Client, res *models.Resource, expected int64) (*models.Resource, error) {
+	var updated *models.Resource
+	var rejected error
+	if expected >= 0 {
+		updated, rejected = client.UpdateIfVersion(res, expected)
+	} else {
+		updated, rejected = client.Update(res)
+	}
+	if !relationshipsRejected(rejected, res.Relationships) {
+		return updated, rejected
+	}
+	utils.DebugLogger.Printf("Inline relationships of %s %s rejected, setting them separately: %v", res.Type, res.ID, rejected)
+	if len(res.Attributes) > 0 {
+		bare := *res
+		bare.Relationships = nil
+		if _, err := client.Update(&bare); err != nil {
+			return nil, retryError(rejected, err)
+		}
+	}
+	if err := setRelationships(client, res.Type, res.ID, res.Relationships, false); err != nil {
+		return nil, retryError(rejected, err)
+	}
+	return client.Read(res.Type, res.ID)
+}
+
+// relationshipsRejected reports whether the server refused a request as
+// invalid because of the relationships it carried: the error has to mention
+// relationships or one of the relations sent. Other rejections, such as an
+// invalid attribute, are final.
+func relationshipsRejected(err error, relationships map[string]models.Relationship) bool {
+	var apiError *api.APIError
+	if len(relationships) == 0 || !errors.As(err, &apiError) {
+		return false
+	}
+	if apiError.StatusCode != http.StatusBadRequest && apiError.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	var messages []string
+	for _, e := range apiError.Errors {
+		messages = append(messages, e.Title, e.Detail)
+	}
+	message := strings.ToLower(strings.Join(messages, " "))
+	if strings.Contains(message, "relation") {
+		return true
+	}
+	for name := range relationships {
+		if strings.Contains(message, strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryError reports that a request sent again without its relationships
+// failed too, starting with the reason the first one was rejected.
+func retryError(rejected, err error) error {
+	return fmt.Errorf("%v; retrying without relationships failed too: %w", rejected, err)
+}
+
+// readLinked reads a record with the linkage of the named relationships.
+func readLinked(client *api.Client, resourceType, id string, relations []string) (*models.Resource, error) {
+	doc, err := client.ReadDocument(resourceType, id, strings.Join(relations, ","))
+	if err != nil {
+		return nil, err
+	}
+	resources, err := doc.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("%s %s not found", resourceType, id)
+	}
+	return resources[0], nil
+}
+
+// setRelationships sets the linkage of relationships through the
+// relationship endpoints, replacing what the record links to. Empty to-one
+// relationships are cleared, unless the record was just created and has
+// none.
+func setRelationships(client *api.Client, resourceType, id string, relationships map[string]models.Relationship, created bool) error {
+	names := make([]string, 0, len(relationships))
+	for name := range relationships {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failed []string
+	for _, name := range names {
+		rel := relationships[name]
+		identifiers := rel.Identifiers()
+		var err error
+		switch {
+		case rel.IsToMany():
+			if identifiers == nil {
+				identifiers = []models.ResourceIdentifier{}
+			}
+			if len(identifiers) > 0 || !created {
+				_, err = client.UpdateRelationship(resourceType, id, name, identifiers)
+			}
+		case len(identifiers) > 0:
+			_, err = client.UpdateRelationship(resourceType, id, name, identifiers[0])
+		case !created:
+			// Linkage cannot be set to nothing, so unlink what is there now
+			var current *models.Document
+			if current, err = client.GetRelationship(resourceType, id, name); err == nil {
+				for _, identifier := range (models.Relationship{Data: current.Data}).Identifiers() {
+					if err = client.DeleteFromRelationship(resourceType, id, name, identifier); err != nil {
+						break
+					}
+				}
+			}
+		}
+		if isDryRun(err) {
+			return err
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to set relationships: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
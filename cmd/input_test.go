@@ -0,0 +1,113 @@
+// cmd/input_test.go
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+)
+
+// linkServer answers creates of user_account records: a body with
+// relationships is rejected with inlineError, one without them with
+// bareError, or succeeds when that is empty. It records the requests it gets.
+func linkServer(t *testing.T, inlineError, bareError string) (*api.Client, *[]string) {
+	t.Helper()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		inline := strings.Contains(string(body), `"relationships"`)
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		switch {
+		case r.Method == http.MethodPost && inline:
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"errors":[{"status":"400","title":"invalid request","detail":"`+inlineError+`"}]}`)
+		case r.Method == http.MethodPost && bareError != "":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, `{"errors":[{"status":"422","title":"invalid request","detail":"`+bareError+`"}]}`)
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"data":{"type":"user_account","id":"u1","attributes":{"name":"Al"}}}`)
+		default:
+			io.WriteString(w, `{"data":{"type":"usergroup","id":"g1"}}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := api.NewClient(server.URL+"/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, &requests
+}
+
+func TestCreateLinkedRetry(t *testing.T) {
+	utils.InitLogger(false)
+	res := &models.Resource{
+		Type:          "user_account",
+		Attributes:    map[string]interface{}{"name": "Al"},
+		Relationships: map[string]models.Relationship{"usergroup_id": {Data: []interface{}{map[string]interface{}{"type": "usergroup", "id": "g1"}}}},
+	}
+
+	tests := []struct {
+		name         string
+		inlineError  string
+		bareError    string
+		wantRequests string
+		wantError    []string
+	}{
+		{
+			"relationships rejected",
+			"relationships are not accepted here", "",
+			"POST /api/user_account,POST /api/user_account,PATCH /api/user_account/u1/relationships/usergroup_id",
+			nil,
+		},
+		{
+			"a relation rejected by name",
+			"unknown field usergroup_id", "",
+			"POST /api/user_account,POST /api/user_account,PATCH /api/user_account/u1/relationships/usergroup_id",
+			nil,
+		},
+		{
+			"other rejections are not retried",
+			"name is too long", "",
+			"POST /api/user_account",
+			[]string{"name is too long"},
+		},
+		{
+			"the retry fails too",
+			"relationships are not accepted here", "email is required",
+			"POST /api/user_account,POST /api/user_account",
+			[]string{"relationships are not accepted here", "email is required"},
+		},
+	}
+	for _, tt := range tests {
+		client, requests := linkServer(t, tt.inlineError, tt.bareError)
+		_, err := createLinked(client, res)
+		if got := strings.Join(*requests, ","); got != tt.wantRequests {
+			t.Errorf("%s: requests = %s, want %s", tt.name, got, tt.wantRequests)
+		}
+		if tt.wantError == nil {
+			if err != nil {
+				t.Errorf("%s: createLinked failed: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: createLinked succeeded, want an error", tt.name)
+			continue
+		}
+		for _, want := range tt.wantError {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: createLinked failed with %q, want it to mention %q", tt.name, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// cmd/interactive.go
+
+package main
+
+import (
+	"bufio"
+	"dcli/api"
+	"dcli/models"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// prompter asks questions on stderr and reads the answers from stdin.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPrompter() *prompter {
+	return &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+}
+
+// line asks a question and returns the trimmed answer.
+func (p *prompter) line(question string) (string, error) {
+	fmt.Fprint(p.out, question)
+	answer, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// secret asks a question without echoing the answer when stdin is a
+// terminal.
+func (p *prompter) secret(question string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return p.line(question)
+	}
+	fmt.Fprint(p.out, question)
+	answer, err := term.ReadPassword(fd)
+	fmt.Fprintln(p.out)
+	return string(answer), err
+}
+
+// choose shows numbered choices and returns the indexes picked. Several
+// comma-separated numbers may be picked when many is set. An empty answer
+// picks nothing.
+func (p *prompter) choose(question string, choices []string, many bool) ([]int, error) {
+	for i, choice := range choices {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, choice)
+	}
+	for {
+		answer, err := p.line(question)
+		if err != nil || answer == "" {
+			return nil, err
+		}
+		picked, err := parsePicks(answer, len(choices), many)
+		if err == nil {
+			return picked, nil
+		}
+		fmt.Fprintln(p.out, err)
+	}
+}
+
+func parsePicks(answer string, count int, many bool) ([]int, error) {
+	parts := strings.Split(answer, ",")
+	if len(parts) > 1 && !many {
+		return nil, fmt.Errorf("pick one number between 1 and %d", count)
+	}
+	var picked []int
+	for _, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 || n > count {
+			return nil, fmt.Errorf("%q is not a number between 1 and %d", strings.TrimSpace(part), count)
+		}
+		picked = append(picked, n-1)
+	}
+	return picked, nil
+}
+
+// promptResource asks for the attributes and relations of a new resource,
+// walking the columns of the entity model in schema order. With the ID of an
+// existing record, it shows the current values as defaults instead and
+// returns only what was changed, with the version of the record the answers
+// are based on, or -1 when there is none.
+func promptResource(client *api.Client, resourceType, id string) (*models.Resource, int64, error) {
+	model, err := client.GetEntityModel(resourceType)
+	if err != nil {
+		return nil, -1, fmt.Errorf("failed to fetch the model of %s: %w", resourceType, err)
+	}
+	if model.TableName == "" {
+		model.TableName = resourceType
+	}
+
+	var current *models.Resource
+	if id != "" {
+		doc, err := client.ReadDocument(resourceType, id, strings.Join(relationNames(model), ","))
+		if err != nil {
+			return nil, -1, fmt.Errorf("failed to read %s %s: %w", resourceType, id, err)
+		}
+		resources, err := doc.Resolve()
+		if err != nil {
+			return nil, -1, fmt.Errorf("failed to read %s %s: %w", resourceType, id, err)
+		}
+		if len(resources) == 0 {
+			return nil, -1, fmt.Errorf("%s %s not found", resourceType, id)
+		}
+		current = resources[0]
+	}
+	version := int64(-1)
+	if v, ok := api.ResourceVersion(current); ok {
+		version = v
+	}
+
+	p := newPrompter()
+	resource := &models.Resource{Type: resourceType, ID: id, Attributes: make(map[string]interface{})}
+	if current == nil {
+		fmt.Fprintf(p.out, "Creating %s. Leave optional fields empty to skip them.\n", resourceType)
+	} else {
+		fmt.Fprintf(p.out, "Updating %s %s. Leave fields empty to keep their current value.\n", resourceType, id)
+	}
+
+	order := model.ColumnOrder
+	if len(order) == 0 {
+		order = sortedColumnNames(model)
+	}
+	for _, name := range order {
+		col := model.ColumnModel[name]
+		if isHiddenColumn(name, model) {
+			continue
+		}
+		if col.JsonApi != "" {
+			var linked *models.Relationship
+			if current != nil {
+				rel := current.Relationships[name]
+				linked = &rel
+			}
+			linkage, err := promptRelation(client, p, name, col, linked)
+			if err != nil {
+				return nil, -1, err
+			}
+			if linkage != nil {
+				if resource.Relationships == nil {
+					resource.Relationships = make(map[string]models.Relationship)
+				}
+				resource.Relationships[name] = models.Relationship{Data: linkage}
+			}
+			continue
+		}
+
+		var previous *interface{}
+		if current != nil {
+			value := current.Attributes[name]
+			previous = &value
+		}
+		value, ok, err := promptAttribute(p, name, col, previous)
+		if err != nil {
+			return nil, -1, err
+		}
+		if ok && (previous == nil || col.ColumnType == "password" || plainValue(value) != plainValue(*previous)) {
+			resource.Attributes[name] = value
+		}
+	}
+	return resource, version, nil
+}
+
+func sortedColumnNames(model *api.TableInfo) []string {
+	names := make([]string, 0, len(model.ColumnModel))
+	for name := range model.ColumnModel {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// promptAttribute asks for the value of one column until it is valid. It
+// reports false when the column is skipped: it is nullable or has a default
+// the server applies, or, given the previous value of a record being
+// updated, the answer is left empty to keep it.
+func promptAttribute(p *prompter, name string, col api.ColumnInfo, previous *interface{}) (interface{}, bool, error) {
+	question := name
+	if col.ColumnDescription != "" {
+		question += " - " + col.ColumnDescription
+	}
+	required := !col.IsNullable && col.DefaultValue == "" && previous == nil
+	switch {
+	case previous != nil && col.ColumnType == "password":
+		question += " [unchanged]"
+	case previous != nil && *previous == nil:
+		question += " (currently empty)"
+	case previous != nil:
+		question += fmt.Sprintf(" [%s]", truncateWidth(singleLine(plainValue(*previous)), 40))
+	case col.DefaultValue != "":
+		question += fmt.Sprintf(" [%s]", col.DefaultValue)
+	case !required:
+		question += " (optional)"
+	}
+
+	if len(col.Options) > 0 {
+		choices := make([]string, len(col.Options))
+		for i, option := range col.Options {
+			choices[i] = plainValue(option.Value)
+			if option.Label != "" && option.Label != choices[i] {
+				choices[i] = fmt.Sprintf("%s (%s)", option.Label, choices[i])
+			}
+		}
+		fmt.Fprintln(p.out, question+":")
+		for {
+			picked, err := p.choose("Choice: ", choices, false)
+			if err != nil {
+				return nil, false, err
+			}
+			if len(picked) == 1 {
+				return col.Options[picked[0]].Value, true, nil
+			}
+			if !required {
+				return nil, false, nil
+			}
+			fmt.Fprintf(p.out, "%s is required.\n", name)
+		}
+	}
+
+	for {
+		var answer string
+		var err error
+		if col.ColumnType == "password" {
+			answer, err = p.secret(question + ": ")
+		} else {
+			answer, err = p.line(question + ": ")
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if answer == "" {
+			if !required {
+				return nil, false, nil
+			}
+			fmt.Fprintf(p.out, "%s is required.\n", name)
+			continue
+		}
+		value, err := coerceValue(answer, col)
+		if err != nil {
+			fmt.Fprintf(p.out, "Invalid value for %s (%s): %v\n", name, columnTypeName(col), err)
+			continue
+		}
+		return value, true, nil
+	}
+}
+
+// promptRelation lets the user search the records of a related entity and
+// pick the ones to link. It returns nil when nothing is linked, or, given
+// the current linkage of a record being updated, when it is kept.
+func promptRelation(client *api.Client, p *prompter, name string, col api.ColumnInfo, current *models.Relationship) (interface{}, error) {
+	many := col.JsonApi == "hasMany"
+	noun := "a record"
+	if many {
+		noun = "records"
+	}
+	skip := "empty to skip"
+	if current != nil {
+		linked := "none"
+		if identifiers := current.Identifiers(); len(identifiers) > 0 {
+			ids := make([]string, len(identifiers))
+			for i, identifier := range identifiers {
+				ids[i] = identifier.ID
+			}
+			linked = strings.Join(ids, ", ")
+		}
+		fmt.Fprintf(p.out, "%s links %s of %s, currently %s.\n", name, noun, col.Type, linked)
+		skip = "empty to keep"
+	} else {
+		fmt.Fprintf(p.out, "%s links %s of %s.\n", name, noun, col.Type)
+	}
+
+	for {
+		text, err := p.line(fmt.Sprintf("Search %s (%s): ", col.Type, skip))
+		if err != nil || text == "" {
+			return nil, err
+		}
+		candidates, err := findRecords(client, col.Type, text, 20)
+		if err != nil {
+			fmt.Fprintf(p.out, "Search failed: %v\n", err)
+			continue
+		}
+		if len(candidates) == 0 {
+			fmt.Fprintln(p.out, "No matching records.")
+			continue
+		}
+
+		choices := make([]string, len(candidates))
+		for i, res := range candidates {
+			choices[i] = recordLabel(res)
+		}
+		question := "Pick a number (empty to search again): "
+		if many {
+			question = "Pick numbers, comma-separated (empty to search again): "
+		}
+		picked, err := p.choose(question, choices, many)
+		if err != nil {
+			return nil, err
+		}
+		if len(picked) == 0 {
+			continue
+		}
+
+		if !many {
+			res := candidates[picked[0]]
+			return models.ResourceIdentifier{Type: res.Type, ID: res.ID}, nil
+		}
+		linkage := make([]models.ResourceIdentifier, len(picked))
+		for i, n := range picked {
+			linkage[i] = models.ResourceIdentifier{Type: candidates[n].Type, ID: candidates[n].ID}
+		}
+		return linkage, nil
+	}
+}
+
+// findRecords returns up to limit records of an entity whose searchable
+// columns contain text, in the order the columns are searched.
+func findRecords(client *api.Client, entity, text string, limit int) ([]*models.Resource, error) {
+	model, err := client.GetEntityModel(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*models.Resource
+	seen := make(map[string]struct{})
+	for _, column := range model.SearchableColumns() {
+		doc, err := client.List(entity, &api.ListOptions{
+			Page:  map[string]string{"size": strconv.Itoa(limit)},
+			Query: []api.Condition{{Column: column, Operator: "like", Value: "%" + text + "%"}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		resources, err := doc.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range resources {
+			if _, ok := seen[res.ID]; ok {
+				continue
+			}
+			seen[res.ID] = struct{}{}
+			found = append(found, res)
+			if len(found) == limit {
+				return found, nil
+			}
+		}
+	}
+	return found, nil
+}
+
+// recordLabel describes a record by its ID and its first naming attribute.
+func recordLabel(res *models.Resource) string {
+	for _, key := range []string{"name", "title", "label", "email", "username"} {
+		if value, ok := res.Attributes[key]; ok && value != nil {
+			return fmt.Sprintf("%s  %s", res.ID, singleLine(plainValue(value)))
+		}
+	}
+	return res.ID
+}
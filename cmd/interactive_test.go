@@ -0,0 +1,65 @@
+// cmd/interactive_test.go
+
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const userAccountModel = `{"TableName":"user_account","ColumnModel":{
+	"name":{"ColumnName":"name","ColumnType":"label","DataType":"varchar(50)"},
+	"email":{"ColumnName":"email","ColumnType":"email","DataType":"varchar(80)","IsNullable":true}
+}}`
+
+func TestUpdateInteractive(t *testing.T) {
+	f := newFakeServer(t)
+	f.models["user_account"] = userAccountModel
+	f.add("user_account", "u1", map[string]interface{}{"name": "Al", "email": "al@x.com"}, nil)
+
+	_, stderr, code := f.run("Bob\n\n", "update", "-type", "user_account", "-id", "u1", "-interactive")
+	if code != 0 {
+		t.Fatalf("update -interactive exited with %d: %s", code, stderr)
+	}
+	if got := f.record("user_account", "u1").Attributes; got["name"] != "Bob" || got["email"] != "al@x.com" {
+		t.Errorf("after update -interactive, the record is %v", got)
+	}
+}
+
+// A record changed while the answers were given is not overwritten.
+func TestUpdateInteractiveConflict(t *testing.T) {
+	f := newFakeServer(t)
+	f.models["user_account"] = userAccountModel
+	f.add("user_account", "u1", map[string]interface{}{"name": "Al"}, nil)
+
+	// The first read fills in the prompts; someone else changes the record
+	// before the next one
+	var once sync.Once
+	reads := 0
+	f.before = func(r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/user_account/u1" {
+			return
+		}
+		if reads++; reads == 2 {
+			once.Do(func() {
+				f.mu.Lock()
+				defer f.mu.Unlock()
+				record := f.records["user_account"]["u1"]
+				record.Attributes["name"], record.Attributes["version"] = "Carol", float64(2)
+			})
+		}
+	}
+
+	_, stderr, code := f.run("Bob\n\n", "update", "-type", "user_account", "-id", "u1", "-interactive")
+	if code != 1 || !strings.Contains(stderr, "conflict") {
+		t.Errorf("update -interactive of a changed record exited with %d: %s", code, stderr)
+	}
+	if patches := f.sent(http.MethodPatch); len(patches) > 0 {
+		t.Errorf("update -interactive of a changed record sent %v", patches)
+	}
+	if got := f.record("user_account", "u1").Attributes["name"]; got != "Carol" {
+		t.Errorf("name = %v, want the concurrent change kept", got)
+	}
+}
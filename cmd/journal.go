@@ -0,0 +1,398 @@
+// cmd/journal.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"dcli/api"
+	"dcli/models"
+	"dcli/utils"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalEntry records a change made to a resource together with the state
+// it replaced, so that it can be undone. Entries of kind "undo" record which
+// entries were undone.
+type journalEntry struct {
+	ID         int              `json:"id"`
+	Time       time.Time        `json:"time"`
+	Run        int              `json:"run"` // ID of the first entry written by the same command
+	Command    string           `json:"command"`
+	Kind       string           `json:"kind"` // update, delete, relation, permission or undo
+	Type       string           `json:"type,omitempty"`
+	ResourceID string           `json:"resource_id,omitempty"`
+	Before     *models.Resource `json:"before,omitempty"` // The attributes and relationships the change replaced
+	Undoes     []int            `json:"undoes,omitempty"`
+}
+
+// serverManagedColumns are set by the server and cannot be written when a
+// record is created.
+var serverManagedColumns = []string{"id", "reference_id", "version", "created_at", "updated_at"}
+
+// journal is the api.Recorder that appends the changes made with a profile
+// to its journal file, reading the state each change replaces just before
+// it is made.
+type journal struct {
+	client  *api.Client
+	path    string
+	command string
+
+	mu  sync.Mutex
+	run int // ID of the first entry this command wrote, 0 until then
+}
+
+// journalLockWait is how long append waits for another dcli to finish
+// writing the journal, and journalLockStale the age at which a lock file is
+// taken to be left over from a dcli that died while holding it.
+const (
+	journalLockWait  = 10 * time.Second
+	journalLockStale = 30 * time.Second
+)
+
+func openJournal(client *api.Client, profile, command string) *journal {
+	return &journal{client: client, path: journalPath(profile), command: command}
+}
+
+// journalPath returns the journal file of a profile, under ~/.dcli/journal.
+func journalPath(profile string) string {
+	if profile == "" {
+		profile = utils.DefaultProfile
+	}
+	return filepath.Join(filepath.Dir(utils.DefaultConfigPath()), "journal", url.PathEscape(profile)+".ndjson")
+}
+
+func (j *journal) Before(change api.Change) func(error) {
+	before, captureErr := j.capture(change)
+	return func(err error) {
+		if err != nil {
+			return
+		}
+		if captureErr != nil {
+			utils.ErrorLogger.Printf("The %s of %s %s cannot be undone: failed to read it beforehand: %v", change.Kind, change.Type, change.ID, captureErr)
+			return
+		}
+		entry := &journalEntry{Kind: string(change.Kind), Type: change.Type, ResourceID: change.ID, Before: before}
+		if err := j.append(entry); err != nil {
+			utils.ErrorLogger.Printf("The %s of %s %s cannot be undone: failed to write the journal: %v", change.Kind, change.Type, change.ID, err)
+		}
+	}
+}
+
+// capture reads what a change is about to replace: the permission, the
+// written attributes and relationships, or, for a delete, the whole record
+// with the linkage of every relationship.
+func (j *journal) capture(change api.Change) (*models.Resource, error) {
+	if change.Kind == api.ChangePermission {
+		perm, err := j.client.GetPermissions(change.Type, change.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.Resource{Type: change.Type, ID: change.ID, Attributes: map[string]interface{}{"permission": int64(perm)}}, nil
+	}
+
+	relations := change.Relations
+	if change.Kind == api.ChangeDelete {
+		relations = nil
+		if model, err := j.client.GetEntityModel(change.Type); err == nil {
+			for _, name := range model.ColumnOrder {
+				if col := model.ColumnModel[name]; col.JsonApi != "" && !col.ExcludeFromApi {
+					relations = append(relations, name)
+				}
+			}
+		}
+	}
+	doc, err := j.client.ReadDocument(change.Type, change.ID, strings.Join(relations, ","))
+	if err != nil {
+		return nil, err
+	}
+	resources, err := doc.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("%s %s not found", change.Type, change.ID)
+	}
+	current := resources[0]
+
+	before := &models.Resource{Type: change.Type, ID: change.ID, Attributes: map[string]interface{}{}}
+	if change.Kind == api.ChangeDelete {
+		before.Attributes = current.Attributes
+	}
+	for _, name := range change.Attributes {
+		before.Attributes[name] = current.Attributes[name]
+	}
+	for _, name := range relations {
+		rel, ok := current.Relationships[name]
+		if !ok {
+			continue
+		}
+		if before.Relationships == nil {
+			before.Relationships = make(map[string]models.Relationship)
+		}
+		before.Relationships[name] = linkageOnly(rel)
+	}
+	return before, nil
+}
+
+// linkageOnly returns the identifiers of a relationship without its links
+// and included resources. Empty to-many linkage is kept as an empty list.
+func linkageOnly(rel models.Relationship) models.Relationship {
+	identifiers := rel.Identifiers()
+	if rel.IsToMany() {
+		if identifiers == nil {
+			identifiers = []models.ResourceIdentifier{}
+		}
+		return models.Relationship{Data: identifiers}
+	}
+	if len(identifiers) == 0 {
+		return models.Relationship{}
+	}
+	return models.Relationship{Data: identifiers[0]}
+}
+
+// append writes an entry to the journal, numbering it after the last one.
+// Other dcli processes may write to the same journal, so the file is locked
+// and its last ID read again for every entry.
+func (j *journal) append(entry *journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(j.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	last, err := lastJournalID(j.path)
+	if err != nil {
+		return err
+	}
+	if j.run == 0 {
+		j.run = last + 1
+	}
+	entry.ID, entry.Run, entry.Command, entry.Time = last+1, j.run, j.command, time.Now()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// The journal holds whole records, so it is only readable by its owner
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// lockFile takes a lock by creating path, waiting while another process
+// holds it, and returns the function that releases it.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(journalLockWait)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > journalLockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another dcli; remove it if none is running", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// lastJournalID returns the ID of the last entry of a journal file, reading
+// it from the end, or 0 when there is none.
+func lastJournalID(path string) (int, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var tail []byte
+	for offset := info.Size(); offset > 0; {
+		n := min(offset, 4096)
+		offset -= n
+		chunk := make([]byte, n)
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return 0, err
+		}
+		tail = append(chunk, tail...)
+
+		// Read on until the last line is complete
+		trimmed := bytes.TrimRight(tail, " \t\r\n")
+		start := bytes.LastIndexByte(trimmed, '\n')
+		if start < 0 && offset > 0 {
+			continue
+		}
+		if len(trimmed) == 0 {
+			return 0, nil
+		}
+		var entry struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(trimmed[start+1:], &entry); err != nil {
+			return 0, fmt.Errorf("%s: last entry: %w", path, err)
+		}
+		return entry.ID, nil
+	}
+	return 0, nil
+}
+
+// readJournal reads the entries of a journal file in the order they were
+// written. A missing file is an empty journal.
+func readJournal(path string) ([]*journalEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*journalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, scanner.Err()
+}
+
+// undoneEntries returns, for every undone entry, the ID of the entry that
+// undid it.
+func undoneEntries(entries []*journalEntry) map[int]int {
+	undone := make(map[int]int)
+	for _, entry := range entries {
+		for _, id := range entry.Undoes {
+			undone[id] = entry.ID
+		}
+	}
+	return undone
+}
+
+// describe summarizes what an entry changed.
+func (e *journalEntry) describe() string {
+	switch e.Kind {
+	case "undo":
+		ids := make([]string, len(e.Undoes))
+		for i, id := range e.Undoes {
+			ids[i] = fmt.Sprintf("#%d", id)
+		}
+		return "undid " + strings.Join(ids, ", ")
+	case string(api.ChangeDelete):
+		if e.Before != nil {
+			return strings.TrimSpace(strings.TrimPrefix(recordLabel(e.Before), e.ResourceID))
+		}
+	case string(api.ChangePermission):
+		if perm, ok := e.permission(); ok {
+			return fmt.Sprintf("was %d", perm)
+		}
+	}
+	if e.Before == nil {
+		return ""
+	}
+	names := sortedKeys(e.Before.Attributes)
+	for name := range e.Before.Relationships {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// permission returns the permission a permission change replaced.
+func (e *journalEntry) permission() (api.AuthPermission, bool) {
+	if e.Before == nil {
+		return 0, false
+	}
+	value, ok := e.Before.Attributes["permission"].(float64)
+	return api.AuthPermission(value), ok
+}
+
+// undo reverses the change an entry records and returns what was done.
+func (e *journalEntry) undo(client *api.Client) (string, error) {
+	if e.Before == nil {
+		return "", fmt.Errorf("#%d does not record the previous state", e.ID)
+	}
+	switch e.Kind {
+	case string(api.ChangePermission):
+		perm, ok := e.permission()
+		if !ok {
+			return "", fmt.Errorf("#%d does not record the previous permission", e.ID)
+		}
+		if err := client.SetPermissions(e.Type, e.ResourceID, perm); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("restored the permission of %s %s", e.Type, e.ResourceID), nil
+
+	case string(api.ChangeDelete):
+		attributes := make(map[string]interface{}, len(e.Before.Attributes))
+		for name, value := range e.Before.Attributes {
+			attributes[name] = value
+		}
+		for _, name := range serverManagedColumns {
+			delete(attributes, name)
+		}
+		created, err := client.Create(&models.Resource{Type: e.Type, Attributes: attributes})
+		if err != nil {
+			return "", err
+		}
+		done := fmt.Sprintf("re-created %s %s as %s", e.Type, e.ResourceID, created.ID)
+		if err := setRelationships(client, e.Type, created.ID, e.Before.Relationships, true); err != nil {
+			return done, err
+		}
+		return done, nil
+
+	case string(api.ChangeUpdate):
+		if len(e.Before.Attributes) > 0 {
+			res := &models.Resource{Type: e.Type, ID: e.ResourceID, Attributes: e.Before.Attributes}
+			if _, err := client.Update(res); err != nil {
+				return "", err
+			}
+		}
+		fallthrough
+	case string(api.ChangeRelation):
+		done := fmt.Sprintf("reverted %s %s", e.Type, e.ResourceID)
+		if err := setRelationships(client, e.Type, e.ResourceID, e.Before.Relationships, false); err != nil {
+			return "", err
+		}
+		return done, nil
+	}
+	return "", fmt.Errorf("#%d is a %s, which cannot be undone", e.ID, e.Kind)
+}
@@ -0,0 +1,131 @@
+// cmd/journal_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Journals of separate processes writing the same file number their entries
+// one after the other.
+func TestJournalConcurrentAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal", "default.ndjson")
+	journals := []*journal{{path: path, command: "one"}, {path: path, command: "two"}}
+
+	const perJournal = 40
+	var wg sync.WaitGroup
+	for _, j := range journals {
+		wg.Add(1)
+		go func(j *journal) {
+			defer wg.Done()
+			for i := 0; i < perJournal; i++ {
+				if err := j.append(&journalEntry{Kind: "update", Type: "user_account", ResourceID: j.command}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(j)
+	}
+	wg.Wait()
+
+	entries, err := readJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2*perJournal {
+		t.Fatalf("the journal has %d entries, want %d", len(entries), 2*perJournal)
+	}
+	runs := make(map[string]int)
+	for i, entry := range entries {
+		if entry.ID != i+1 {
+			t.Fatalf("entry %d has ID %d", i+1, entry.ID)
+		}
+		if _, ok := runs[entry.Command]; !ok {
+			runs[entry.Command] = entry.ID
+		}
+		if entry.Run != runs[entry.Command] {
+			t.Errorf("entry %d of %s is in run %d, want %d", entry.ID, entry.Command, entry.Run, runs[entry.Command])
+		}
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("the lock file is left behind: %v", err)
+	}
+}
+
+func TestLastJournalID(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("x", 10000)
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{"", 0},
+		{"\n\n", 0},
+		{`{"id":1}` + "\n", 1},
+		{`{"id":1}` + "\n" + `{"id":2}`, 2},
+		{`{"id":1}` + "\n" + `{"id":12,"command":"` + long + `"}` + "\n\n", 12},
+		{`{"id":7,"command":"` + long + `"}` + "\n" + strings.Repeat("\n", 5000), 7},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "journal.ndjson")
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := lastJournalID(path); err != nil || got != tt.want {
+			t.Errorf("case %d: lastJournalID = %d, %v, want %d", i+1, got, err, tt.want)
+		}
+	}
+
+	if got, err := lastJournalID(filepath.Join(dir, "missing.ndjson")); err != nil || got != 0 {
+		t.Errorf("lastJournalID of a missing journal = %d, %v, want 0", got, err)
+	}
+	path := filepath.Join(dir, "broken.ndjson")
+	os.WriteFile(path, []byte(`{"id":1}`+"\n"+`{"id":`), 0o600)
+	if _, err := lastJournalID(path); err == nil {
+		t.Error("lastJournalID of a truncated entry succeeded, want an error")
+	}
+}
+
+// A journaled update is undone, and the undo is recorded.
+func TestUndoUpdate(t *testing.T) {
+	f := newFakeServer(t)
+	f.models["user_account"] = userAccountModel
+	f.add("user_account", "u1", map[string]interface{}{"name": "Al", "email": "al@x.com"}, nil)
+
+	if _, stderr, code := f.run("", "update", "-type", "user_account", "-id", "u1", "--set", "name=Bob"); code != 0 {
+		t.Fatalf("update exited with %d: %s", code, stderr)
+	}
+	if got := f.record("user_account", "u1").Attributes["name"]; got != "Bob" {
+		t.Fatalf("name after the update = %v", got)
+	}
+
+	if _, stderr, code := f.run("", "undo", "--yes"); code != 0 {
+		t.Fatalf("undo exited with %d: %s", code, stderr)
+	}
+	attributes := f.record("user_account", "u1").Attributes
+	if attributes["name"] != "Al" || attributes["email"] != "al@x.com" {
+		t.Errorf("after the undo, the record is %v", attributes)
+	}
+
+	stdout, stderr, code := f.run("", "history", "-o", "json")
+	if code != 0 {
+		t.Fatalf("history exited with %d: %s", code, stderr)
+	}
+	var entries []journalEntry
+	if err := json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatalf("history printed %s: %v", stdout, err)
+	}
+	if len(entries) != 2 || entries[0].Kind != "undo" || len(entries[0].Undoes) != 1 || entries[0].Undoes[0] != entries[1].ID {
+		t.Errorf("history = %s", stdout)
+	}
+
+	// The change was undone, so there is nothing left to undo
+	if _, stderr, code := f.run("", "undo", "--yes"); code != 1 || !strings.Contains(stderr, "Nothing to undo.") {
+		t.Errorf("a second undo exited with %d: %s", code, stderr)
+	}
+}
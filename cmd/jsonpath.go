@@ -0,0 +1,544 @@
+// cmd/jsonpath.go
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// jsonPathRenderer evaluates a kubectl-style JSONPath template against the
+// structured form of a view, e.g. '{.data[*].attributes.name}' or
+// '{range .data[*]}{.id}{"\t"}{.attributes.email}{"\n"}{end}'.
+type jsonPathRenderer struct {
+	template *jsonPathTemplate
+}
+
+func newJSONPathRenderer(text string) (Renderer, error) {
+	template, err := parseJSONPathTemplate(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonpath: %w", err)
+	}
+	return jsonPathRenderer{template: template}, nil
+}
+
+func (r jsonPathRenderer) Render(w io.Writer, v *View) error {
+	data, err := toGeneric(v.structured())
+	if err != nil {
+		return err
+	}
+	var out strings.Builder
+	if err := r.template.execute(&out, data); err != nil {
+		return err
+	}
+	text := out.String()
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	_, err = io.WriteString(w, text)
+	return err
+}
+
+// jsonPathTemplate is literal text mixed with {expressions}. Expressions are
+// paths, quoted string literals, or range/end blocks.
+type jsonPathTemplate struct {
+	nodes []jsonPathNode
+}
+
+type jsonPathNode struct {
+	text  string         // Literal text, when path is nil
+	path  []jsonPathStep // Expression to print, or to range over for blocks
+	body  []jsonPathNode // Nodes repeated for every value of path
+	block bool           // Whether the node is a {range} block
+}
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepWildcard
+	stepRecursive
+	stepIndex
+	stepSlice
+	stepFilter
+)
+
+type jsonPathStep struct {
+	kind   stepKind
+	name   string
+	index  int
+	start  *int
+	end    *int
+	filter *jsonPathFilter
+}
+
+// jsonPathFilter is a [?(@.path op literal)] predicate. A filter without an
+// operator only checks that the path exists.
+type jsonPathFilter struct {
+	path     []jsonPathStep
+	operator string
+	value    interface{}
+}
+
+func parseJSONPathTemplate(text string) (*jsonPathTemplate, error) {
+	type frame struct {
+		node  *jsonPathNode
+		nodes []jsonPathNode
+	}
+	stack := []frame{{}}
+	appendNode := func(node jsonPathNode) {
+		top := &stack[len(stack)-1]
+		top.nodes = append(top.nodes, node)
+	}
+
+	for len(text) > 0 {
+		open := strings.IndexByte(text, '{')
+		if open < 0 {
+			appendNode(jsonPathNode{text: text})
+			break
+		}
+		if open > 0 {
+			appendNode(jsonPathNode{text: text[:open]})
+		}
+		closeIndex, err := matchingBrace(text, open)
+		if err != nil {
+			return nil, err
+		}
+		expr := strings.TrimSpace(text[open+1 : closeIndex])
+		text = text[closeIndex+1:]
+
+		switch {
+		case expr == "end":
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("{end} without {range}")
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			top.node.body = top.nodes
+			appendNode(*top.node)
+		case strings.HasPrefix(expr, "range "):
+			path, err := parseJSONPath(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, frame{node: &jsonPathNode{path: path, block: true}})
+		case strings.HasPrefix(expr, `"`):
+			literal, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string literal %s", expr)
+			}
+			appendNode(jsonPathNode{text: literal})
+		case strings.HasPrefix(expr, "'") && strings.HasSuffix(expr, "'") && len(expr) >= 2:
+			appendNode(jsonPathNode{text: expr[1 : len(expr)-1]})
+		default:
+			path, err := parseJSONPath(expr)
+			if err != nil {
+				return nil, err
+			}
+			appendNode(jsonPathNode{path: path})
+		}
+	}
+
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("{range} without {end}")
+	}
+	return &jsonPathTemplate{nodes: stack[0].nodes}, nil
+}
+
+// matchingBrace returns the index of the brace closing the one at open,
+// ignoring braces inside quoted strings.
+func matchingBrace(text string, open int) (int, error) {
+	var quote byte
+	for i := open + 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unclosed { in %q", text[open:])
+}
+
+// parseJSONPath parses a path such as $.data[*].attributes['name'].
+func parseJSONPath(expr string) ([]jsonPathStep, error) {
+	expr = strings.TrimPrefix(strings.TrimPrefix(expr, "$"), "@")
+	// Not nil even when empty: {@} and {$} print the current value
+	steps := []jsonPathStep{}
+	for len(expr) > 0 {
+		switch {
+		case strings.HasPrefix(expr, ".."):
+			steps = append(steps, jsonPathStep{kind: stepRecursive})
+			expr = expr[1:]
+		case expr[0] == '.':
+			expr = expr[1:]
+			if strings.HasPrefix(expr, "*") {
+				steps = append(steps, jsonPathStep{kind: stepWildcard})
+				expr = expr[1:]
+				continue
+			}
+			end := strings.IndexAny(expr, ".[")
+			if end < 0 {
+				end = len(expr)
+			}
+			if end > 0 {
+				steps = append(steps, jsonPathStep{kind: stepField, name: expr[:end]})
+			}
+			expr = expr[end:]
+		case expr[0] == '[':
+			closeIndex, err := matchingBracket(expr)
+			if err != nil {
+				return nil, err
+			}
+			step, err := parseBracket(strings.TrimSpace(expr[1:closeIndex]))
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			expr = expr[closeIndex+1:]
+		default:
+			end := strings.IndexAny(expr, ".[")
+			if end < 0 {
+				end = len(expr)
+			}
+			steps = append(steps, jsonPathStep{kind: stepField, name: expr[:end]})
+			expr = expr[end:]
+		}
+	}
+	return steps, nil
+}
+
+func matchingBracket(expr string) (int, error) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unclosed [ in %q", expr)
+}
+
+func parseBracket(content string) (jsonPathStep, error) {
+	switch {
+	case content == "*":
+		return jsonPathStep{kind: stepWildcard}, nil
+	case strings.HasPrefix(content, "?(") && strings.HasSuffix(content, ")"):
+		filter, err := parseJSONPathFilter(content[2 : len(content)-1])
+		if err != nil {
+			return jsonPathStep{}, err
+		}
+		return jsonPathStep{kind: stepFilter, filter: filter}, nil
+	case len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0]:
+		return jsonPathStep{kind: stepField, name: content[1 : len(content)-1]}, nil
+	case strings.Contains(content, ":"):
+		parts := strings.SplitN(content, ":", 2)
+		step := jsonPathStep{kind: stepSlice}
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return jsonPathStep{}, fmt.Errorf("invalid slice [%s]", content)
+			}
+			if i == 0 {
+				step.start = &n
+			} else {
+				step.end = &n
+			}
+		}
+		return step, nil
+	}
+	n, err := strconv.Atoi(content)
+	if err != nil {
+		return jsonPathStep{}, fmt.Errorf("invalid index [%s]", content)
+	}
+	return jsonPathStep{kind: stepIndex, index: n}, nil
+}
+
+func parseJSONPathFilter(expr string) (*jsonPathFilter, error) {
+	expr = strings.TrimSpace(expr)
+	if i, operator := filterOperator(expr); i >= 0 {
+		path, err := parseJSONPath(strings.TrimSpace(expr[:i]))
+		if err != nil {
+			return nil, err
+		}
+		return &jsonPathFilter{
+			path:     path,
+			operator: operator,
+			value:    parseFilterLiteral(strings.TrimSpace(expr[i+len(operator):])),
+		}, nil
+	}
+	path, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonPathFilter{path: path}, nil
+}
+
+// filterOperator returns the first comparison operator of a filter outside
+// quoted strings and its index, or -1 when there is none.
+func filterOperator(expr string) (int, string) {
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		default:
+			for _, operator := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+				if strings.HasPrefix(expr[i:], operator) {
+					return i, operator
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+func parseFilterLiteral(literal string) interface{} {
+	if len(literal) >= 2 && (literal[0] == '\'' || literal[0] == '"') && literal[len(literal)-1] == literal[0] {
+		return literal[1 : len(literal)-1]
+	}
+	switch literal {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if f, err := strconv.ParseFloat(literal, 64); err == nil {
+		return f
+	}
+	return literal
+}
+
+func (t *jsonPathTemplate) execute(out *strings.Builder, data interface{}) error {
+	return executeJSONPathNodes(out, t.nodes, data)
+}
+
+func executeJSONPathNodes(out *strings.Builder, nodes []jsonPathNode, data interface{}) error {
+	for _, node := range nodes {
+		switch {
+		case node.block:
+			for _, value := range evaluateJSONPath(node.path, []interface{}{data}) {
+				if err := executeJSONPathNodes(out, node.body, value); err != nil {
+					return err
+				}
+			}
+		case node.path != nil:
+			values := evaluateJSONPath(node.path, []interface{}{data})
+			texts := make([]string, len(values))
+			for i, value := range values {
+				texts[i] = jsonPathText(value)
+			}
+			out.WriteString(strings.Join(texts, " "))
+		default:
+			out.WriteString(node.text)
+		}
+	}
+	return nil
+}
+
+func evaluateJSONPath(steps []jsonPathStep, values []interface{}) []interface{} {
+	for _, step := range steps {
+		var next []interface{}
+		for _, value := range values {
+			next = append(next, applyJSONPathStep(step, value)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func applyJSONPathStep(step jsonPathStep, value interface{}) []interface{} {
+	switch step.kind {
+	case stepField:
+		if m, ok := value.(map[string]interface{}); ok {
+			if field, ok := m[step.name]; ok {
+				return []interface{}{field}
+			}
+		}
+	case stepWildcard:
+		return children(value)
+	case stepRecursive:
+		return descendants(value)
+	case stepIndex:
+		if list, ok := value.([]interface{}); ok {
+			i := step.index
+			if i < 0 {
+				i += len(list)
+			}
+			if i >= 0 && i < len(list) {
+				return []interface{}{list[i]}
+			}
+		}
+	case stepSlice:
+		if list, ok := value.([]interface{}); ok {
+			start, end := 0, len(list)
+			if step.start != nil {
+				start = clampIndex(*step.start, len(list))
+			}
+			if step.end != nil {
+				end = clampIndex(*step.end, len(list))
+			}
+			if start < end {
+				return list[start:end]
+			}
+		}
+	case stepFilter:
+		var matched []interface{}
+		for _, child := range children(value) {
+			if step.filter.matches(child) {
+				matched = append(matched, child)
+			}
+		}
+		return matched
+	}
+	return nil
+}
+
+func clampIndex(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+// children returns the elements of a list, or the values of a map in key order.
+func children(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := sortedKeys(v)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = v[key]
+		}
+		return values
+	}
+	return nil
+}
+
+// descendants returns value followed by every value nested in it.
+func descendants(value interface{}) []interface{} {
+	all := []interface{}{value}
+	for _, child := range children(value) {
+		all = append(all, descendants(child)...)
+	}
+	return all
+}
+
+func (f *jsonPathFilter) matches(value interface{}) bool {
+	found := evaluateJSONPath(f.path, []interface{}{value})
+	if f.operator == "" {
+		return len(found) > 0
+	}
+	for _, candidate := range found {
+		if compareFilterValues(candidate, f.operator, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareFilterValues(left interface{}, operator string, right interface{}) bool {
+	if l, ok := toFloat(left); ok {
+		if r, ok := toFloat(right); ok {
+			switch operator {
+			case "==":
+				return l == r
+			case "!=":
+				return l != r
+			case ">":
+				return l > r
+			case ">=":
+				return l >= r
+			case "<":
+				return l < r
+			case "<=":
+				return l <= r
+			}
+		}
+	}
+	l, r := jsonPathText(left), jsonPathText(right)
+	switch operator {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// jsonPathText formats a value for output: strings as-is, scalars with %v
+// and objects or lists as compact JSON.
+func jsonPathText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,252 @@
+// cmd/jsonpath_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const jsonPathTestData = `{
+	"data": [
+		{"id": "1", "attributes": {"name": "Alice", "age": 30, "admin": true, "tags": ["a", "b"]}},
+		{"id": "2", "attributes": {"name": "Bob", "age": 25, "admin": false, "tags": []}},
+		{"id": "3", "attributes": {"name": "Carol", "age": 41.5, "admin": false, "note": "x==y"}}
+	],
+	"meta": {"total": 3}
+}`
+
+func jsonPathData(t testing.TB) interface{} {
+	data, err := toGeneric(json.RawMessage(jsonPathTestData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func executeJSONPath(text string, data interface{}) (string, error) {
+	template, err := parseJSONPathTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	err = template.execute(&out, data)
+	return out.String(), err
+}
+
+func TestJSONPathExpressions(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		// Fields
+		{`{.meta.total}`, `3`},
+		{`{$.meta.total}`, `3`},
+		{`{meta.total}`, `3`},
+		{`{.data[0].attributes['name']}`, `Alice`},
+		{`{.data[0].attributes["name"]}`, `Alice`},
+		{`{.data[2].attributes.age}`, `41.5`},
+		{`{.data[0].attributes.admin}`, `true`},
+		{`{.missing}`, ``},
+		{`{.data[0].id.deeper}`, ``},
+		{`{.data[0].attributes.name[0]}`, ``},
+
+		// Objects and lists print as compact JSON
+		{`{.data[0].attributes.tags}`, `["a","b"]`},
+		{`{.data[1].attributes.tags}`, `[]`},
+		{`{.data[0].attributes}`, `{"admin":true,"age":30,"name":"Alice","tags":["a","b"]}`},
+		{`{.meta}`, `{"total":3}`},
+		{`{@.meta}`, `{"total":3}`},
+		{`{.data[0].attributes.tags[*]}`, `a b`},
+
+		// Indexes and slices
+		{`{.data[0].id}`, `1`},
+		{`{.data[-1].id}`, `3`},
+		{`{.data[5].id}`, ``},
+		{`{.data[-5].id}`, ``},
+		{`{.data[0:2].id}`, `1 2`},
+		{`{.data[1:].id}`, `2 3`},
+		{`{.data[:-1].id}`, `1 2`},
+		{`{.data[:].id}`, `1 2 3`},
+		{`{.data[-10:10].id}`, `1 2 3`},
+		{`{.data[2:1].id}`, ``},
+		{`{.meta[0]}`, ``},
+
+		// Wildcards and recursive descent
+		{`{.data[*].id}`, `1 2 3`},
+		{`{.data.*.id}`, `1 2 3`},
+		{`{.data[0].attributes.*}`, `true 30 Alice ["a","b"]`},
+		{`{..name}`, `Alice Bob Carol`},
+		{`{.data..tags[0]}`, `a`},
+		{`{..total}`, `3`},
+
+		// Literals and text
+		{`id: {.data[0].id}!`, `id: 1!`},
+		{`plain text`, `plain text`},
+		{`{"a\tb"}`, "a\tb"},
+		{`{"say \"hi\""}`, `say "hi"`},
+		{`{'single'}`, `single`},
+		{`{"{braces}"}`, `{braces}`},
+		{`a}b`, `a}b`},
+
+		// Ranges
+		{`{range .data[*]}{.id}{"\t"}{.attributes.name}{"\n"}{end}`, "1\tAlice\n2\tBob\n3\tCarol\n"},
+		{`{range .data[*]}[{range .attributes.tags[*]}{@}{end}]{end}`, `[ab][][]`},
+		{`{range .missing[*]}x{end}done`, `done`},
+		{`{ range .data[0:2] }{.id},{ end }`, `1,2,`},
+		{`{range .meta}{$}{end}`, `{"total":3}`},
+	}
+	data := jsonPathData(t)
+	for _, tt := range tests {
+		got, err := executeJSONPath(tt.template, data)
+		if err != nil {
+			t.Errorf("%s failed: %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestJSONPathFilters(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{.data[?(@.attributes.age>26)].id}`, `1 3`},
+		{`{.data[?(@.attributes.age>=30)].id}`, `1 3`},
+		{`{.data[?(@.attributes.age<30)].id}`, `2`},
+		{`{.data[?(@.attributes.age<=25)].id}`, `2`},
+		{`{.data[?(@.attributes.age==25)].id}`, `2`},
+		{`{.data[?(@.attributes.age!=25)].id}`, `1 3`},
+		{`{.data[?(@.attributes.age == 41.5)].id}`, `3`},
+		{`{.data[?(@.attributes.name=='Bob')].id}`, `2`},
+		{`{.data[?(@.attributes.name=="Bob")].id}`, `2`},
+		{`{.data[?(@.attributes.name!='Bob')].id}`, `1 3`},
+		{`{.data[?(@.attributes.name>'B')].id}`, `2 3`},
+		{`{.data[?(@.attributes.admin==true)].id}`, `1`},
+		{`{.data[?(@.attributes.admin==false)].id}`, `2 3`},
+		{`{.data[?(@.id=='1')].attributes.name}`, `Alice`},
+
+		// A missing field matches no comparison; strings and numbers compare as text
+		{`{.data[?(@.attributes.note==null)].id}`, ``},
+		{`{.data[?(@.attributes.note!='x')].id}`, `3`},
+		{`{.data[?(@.id==1)].attributes.name}`, `Alice`},
+
+		// Existence
+		{`{.data[?(@.attributes.tags)].id}`, `1 2`},
+		{`{.data[?(@.attributes.note)].id}`, `3`},
+		{`{.data[?(@.attributes.missing)].id}`, ``},
+
+		// Operators inside quoted literals belong to the literal
+		{`{.data[?(@.attributes.note=='x==y')].id}`, `3`},
+		{`{.data[?(@.attributes.name<"B>")].id}`, `1`},
+
+		// Nested brackets, filters on objects and inside ranges
+		{`{.data[?(@.attributes.tags[0]=='a')].id}`, `1`},
+		{`{.data[?(@.attributes.tags[*]=='b')].id}`, `1`},
+		{`{.meta[?(@==3)]}`, `3`},
+		{`{range .data[?(@.attributes.admin==false)]}{.attributes.name};{end}`, `Bob;Carol;`},
+	}
+	data := jsonPathData(t)
+	for _, tt := range tests {
+		got, err := executeJSONPath(tt.template, data)
+		if err != nil {
+			t.Errorf("%s failed: %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestJSONPathInvalid(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{.data`, `unclosed {`},
+		{`{"unterminated}`, `unclosed {`},
+		{`{.data[0}`, `unclosed [`},
+		{`{.data[0]`, `unclosed {`},
+		{`{.data[?(@.a=='x)]}`, `unclosed {`},
+		{`{end}`, `{end} without {range}`},
+		{`{range .data[*]}{.id}`, `{range} without {end}`},
+		{`{range .data[*]}{range .x}{end}`, `{range} without {end}`},
+		{`{range .data[*]}{end}{end}`, `{end} without {range}`},
+		{`{.data[x]}`, `invalid index [x]`},
+		{`{.data[]}`, `invalid index []`},
+		{`{.data[1:x]}`, `invalid slice [1:x]`},
+		{`{.data[1:2:3]}`, `invalid slice [1:2:3]`},
+		{`{"bad\q"}`, `invalid string literal`},
+		{`{range .data[x]}{end}`, `invalid index [x]`},
+		{`{.data[?(@.a[x]==1)]}`, `invalid index [x]`},
+	}
+	for _, tt := range tests {
+		_, err := parseJSONPathTemplate(tt.template)
+		if err == nil {
+			t.Errorf("%s parsed, want an error", tt.template)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s failed with %q, want it to mention %q", tt.template, err, tt.want)
+		}
+	}
+}
+
+func TestJSONPathRenderer(t *testing.T) {
+	renderer, err := newJSONPathRenderer(`{.data[*].id}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	view := &View{Data: map[string]interface{}{"data": []map[string]string{{"id": "a"}, {"id": "b"}}}}
+	if err := renderer.Render(&out, view); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "a b\n"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	// Rows are rendered as objects keyed by column name
+	out.Reset()
+	renderer, _ = newJSONPathRenderer(`{range [*]}{.Name}={.Value}{"\n"}{end}`)
+	view = &View{Columns: columnNames("Name", "Value"), Rows: [][]string{{"a", "1"}, {"b", "2"}}}
+	if err := renderer.Render(&out, view); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "a=1\nb=2\n"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	if _, err := newJSONPathRenderer(`{.data`); err == nil || !strings.HasPrefix(err.Error(), "invalid jsonpath: ") {
+		t.Errorf("newJSONPathRenderer of an invalid template failed with %v", err)
+	}
+}
+
+// FuzzJSONPath checks that no template makes parsing or evaluation panic.
+// The seeds run with go test; go test -fuzz=FuzzJSONPath explores further.
+func FuzzJSONPath(f *testing.F) {
+	for _, seed := range []string{
+		``, `{}`, `{$}`, `{@}`, `{.}`, `{..}`, `{...}`, `{$$}`, `{[}`, `{]}`, `{[]}`, `{[[]]}`,
+		`{[*]}`, `{[:]}`, `{[-1:]}`, `{[:-1]}`, `{[?()]}`, `{[?(@)]}`, `{[?(==)]}`, `{[?(@==)]}`,
+		`{[?(@.a==']')]}`, `{[?(@.a[?(@.b)])]}`, `{range}`, `{range .}{end}{end}`, `{range .}`,
+		`}`, `{'}`, `{''}`, `{"}`, `{""}`, `{"\"}`, `{"\}`, `{{}}`, `{.data[0`, `{.data[0]]}`,
+		`{.data[99999999999999999999]}`, `{.data[-99999999:99999999].id}`, `{..*}`, `{.*.*.*}`,
+		`{range ..}{@}{end}`, `{range .data[*]}{range ..}{.}{end}{end}`, "{\x00}", `{.data['a'b]}`,
+	} {
+		f.Add(seed)
+	}
+	data := jsonPathData(f)
+	f.Fuzz(func(t *testing.T, text string) {
+		template, err := parseJSONPathTemplate(text)
+		if err != nil {
+			return
+		}
+		var out strings.Builder
+		template.execute(&out, data)
+	})
+}
@@ -0,0 +1,607 @@
+// cmd/output.go
+
+package main
+
+import (
+	"bytes"
+	"dcli/utils"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// globalOptions holds the flags accepted both before the subcommand and by
+// every subcommand.
+var globalOptions = struct {
+	output  string
+	wide    bool
+	noPager bool
+	dryRun  bool
+}{
+	output: "table",
+}
+
+func registerGlobalFlags(fs *flag.FlagSet) {
+	usage := "Output format: table, wide, json, yaml, csv, tsv, ndjson, template=<go template>, jsonpath=<expression>"
+	fs.StringVar(&globalOptions.output, "o", globalOptions.output, usage)
+	fs.StringVar(&globalOptions.output, "output", globalOptions.output, usage)
+	fs.BoolVar(&globalOptions.wide, "wide", globalOptions.wide, "Show every column and full values instead of fitting tables to the terminal")
+	fs.BoolVar(&globalOptions.noPager, "no-pager", globalOptions.noPager, "Do not send long output through $PAGER")
+	fs.BoolVar(&globalOptions.dryRun, "dry-run", globalOptions.dryRun, "Print the requests that would change data, with an equivalent curl command, instead of sending them")
+}
+
+// Column is a table column. Wide columns are only shown by the wide format
+// and the machine-readable formats.
+type Column struct {
+	Name string
+	Wide bool
+}
+
+// View is the output of a command, in tabular form for table, wide, csv and
+// tsv output and in structured form for json, yaml and ndjson output.
+type View struct {
+	Title    string
+	Columns  []Column
+	Rows     [][]string
+	Vertical bool          // Show every row as field/value pairs in table formats
+	Message  string        // Shown in place of the table when there are no columns
+	Notes    []string      // Printed below the table in table formats
+	Data     interface{}   // Structured form; defaults to the rows as objects
+	Items    []interface{} // Records written one per line by ndjson; defaults to Data
+	Values   []interface{} // Typed records the template format runs against; defaults to Items
+	Sections []*View       // Further tables printed after this one
+}
+
+// columnNames returns the names of the columns of a view.
+func columnNames(names ...string) []Column {
+	columns := make([]Column, len(names))
+	for i, name := range names {
+		columns[i] = Column{Name: name}
+	}
+	return columns
+}
+
+// structured returns the structured form of the view.
+func (v *View) structured() interface{} {
+	if v.Data != nil {
+		return v.Data
+	}
+	if len(v.Columns) == 0 && len(v.Sections) == 0 {
+		if v.Message != "" {
+			return map[string]string{"message": v.Message}
+		}
+		return nil
+	}
+
+	rows := make([]map[string]string, 0, len(v.Rows))
+	for _, row := range v.Rows {
+		obj := make(map[string]string, len(v.Columns))
+		for i, col := range v.Columns {
+			if i < len(row) {
+				obj[col.Name] = row[i]
+			}
+		}
+		rows = append(rows, obj)
+	}
+	if len(v.Sections) == 0 {
+		return rows
+	}
+
+	out := make(map[string]interface{})
+	if len(v.Columns) > 0 {
+		out[sectionKey(v.Title, "items")] = rows
+	}
+	for _, section := range v.Sections {
+		out[sectionKey(section.Title, "items")] = section.structured()
+	}
+	return out
+}
+
+// keepColumns restricts the view to the named columns, in the order given.
+// Kept columns are shown by every format, wide or not.
+func (v *View) keepColumns(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(v.Columns))
+	for i, col := range v.Columns {
+		index[col.Name] = i
+	}
+
+	positions := make([]int, len(names))
+	for n, name := range names {
+		i, ok := index[name]
+		if !ok {
+			available := make([]string, len(v.Columns))
+			for i, col := range v.Columns {
+				available[i] = col.Name
+			}
+			return fmt.Errorf("unknown column %q, available columns: %s", name, strings.Join(available, ", "))
+		}
+		positions[n] = i
+	}
+
+	v.Columns = columnNames(names...)
+	for r, row := range v.Rows {
+		kept := make([]string, len(positions))
+		for n, i := range positions {
+			if i < len(row) {
+				kept[n] = row[i]
+			}
+		}
+		v.Rows[r] = kept
+	}
+	return nil
+}
+
+func sectionKey(title, fallback string) string {
+	if title == "" {
+		return fallback
+	}
+	return strings.ToLower(strings.ReplaceAll(title, " ", "_"))
+}
+
+// items returns the records written by line-oriented formats.
+func (v *View) items() []interface{} {
+	if v.Items != nil {
+		return v.Items
+	}
+	data := v.structured()
+	if data == nil {
+		return nil
+	}
+	if generic, err := toGeneric(data); err == nil {
+		if list, ok := generic.([]interface{}); ok {
+			return list
+		}
+	}
+	return []interface{}{data}
+}
+
+// Renderer writes views in one output format.
+type Renderer interface {
+	Render(w io.Writer, v *View) error
+}
+
+// newRenderer returns the renderer for an output format. Formats taking an
+// argument are written as name=argument, e.g. template={{.ID}}.
+func newRenderer(format string) (Renderer, error) {
+	if name, argument, ok := strings.Cut(format, "="); ok {
+		switch strings.ToLower(name) {
+		case "template", "go-template":
+			return newTemplateRenderer(argument)
+		case "jsonpath":
+			return newJSONPathRenderer(argument)
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case "", "table":
+		return tableRenderer{wide: globalOptions.wide}, nil
+	case "wide":
+		return tableRenderer{wide: true}, nil
+	case "json":
+		return jsonRenderer{}, nil
+	case "yaml", "yml":
+		return yamlRenderer{}, nil
+	case "csv":
+		return delimitedRenderer{comma: ','}, nil
+	case "tsv":
+		return delimitedRenderer{comma: '\t'}, nil
+	case "ndjson", "jsonl":
+		return ndjsonRenderer{}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// render writes a view to stdout in the selected output format. Tables are
+// fitted to the terminal and decorated only when stdout is one.
+func render(v *View) {
+	renderer, err := newRenderer(globalOptions.output)
+	if err != nil {
+		utils.ErrorLogger.Println("Invalid output format:", err)
+		os.Exit(1)
+	}
+
+	terminal := stdoutTerminal()
+	if table, ok := renderer.(tableRenderer); ok && terminal.isTTY {
+		table.decorate = true
+		if !table.wide {
+			table.width = terminal.width
+		}
+		renderer = table
+	}
+
+	var out bytes.Buffer
+	if err := renderer.Render(&out, v); err != nil {
+		utils.ErrorLogger.Println("Failed to render output:", err)
+		os.Exit(1)
+	}
+	writeOutput(out.Bytes(), terminal)
+}
+
+// isTableOutput reports whether the selected output format is a table meant
+// for people rather than programs.
+func isTableOutput() bool {
+	format := strings.ToLower(globalOptions.output)
+	return format == "" || format == "table" || format == "wide"
+}
+
+// tableRenderer aligns the columns of tables for people to read. When width
+// is set, tables are fitted to it by wrapping cells and leaving out trailing
+// columns, and long values are truncated. Titles, separators and notes are
+// only printed when decorate is set.
+type tableRenderer struct {
+	wide     bool
+	width    int
+	decorate bool
+}
+
+// maxCellWidth is the number of terminal cells a value may take in a table
+// fitted to the terminal.
+const maxCellWidth = 100
+
+// columnGap is the number of spaces between table columns.
+const columnGap = 2
+
+func (r tableRenderer) Render(w io.Writer, v *View) error {
+	if v.Title != "" && r.decorate {
+		fmt.Fprintf(w, "%s:\n", v.Title)
+	}
+
+	var shown []int
+	for i, col := range v.Columns {
+		if !col.Wide || r.wide {
+			shown = append(shown, i)
+		}
+	}
+
+	cell := func(row []string, i int) string {
+		if i >= len(row) {
+			return ""
+		}
+		if r.width > 0 {
+			return truncateWidth(row[i], maxCellWidth)
+		}
+		return row[i]
+	}
+
+	notes := v.Notes
+	switch {
+	case len(v.Columns) == 0:
+		if v.Message != "" {
+			fmt.Fprintln(w, v.Message)
+		}
+	case v.Vertical:
+		for n, row := range v.Rows {
+			if n > 0 {
+				fmt.Fprintln(w)
+			}
+			fields := make([][]string, len(shown))
+			for f, i := range shown {
+				fields[f] = []string{v.Columns[i].Name, cell(row, i)}
+			}
+			r.writeTable(w, []string{"Field", "Value"}, fields)
+		}
+	default:
+		header := make([]string, len(shown))
+		for n, i := range shown {
+			header[n] = v.Columns[i].Name
+		}
+		rows := make([][]string, len(v.Rows))
+		for k, row := range v.Rows {
+			rows[k] = make([]string, len(shown))
+			for n, i := range shown {
+				rows[k][n] = cell(row, i)
+			}
+		}
+		switch hidden := r.writeTable(w, header, rows); {
+		case hidden == 1:
+			notes = append(notes[:len(notes):len(notes)], "1 more column does not fit the terminal; use --wide or -columns to show it.")
+		case hidden > 1:
+			notes = append(notes[:len(notes):len(notes)],
+				fmt.Sprintf("%d more columns do not fit the terminal; use --wide or -columns to show them.", hidden))
+		}
+	}
+
+	if len(notes) > 0 && r.decorate {
+		fmt.Fprintln(w)
+		for _, note := range notes {
+			fmt.Fprintln(w, note)
+		}
+	}
+
+	for _, section := range v.Sections {
+		fmt.Fprintln(w)
+		if err := r.Render(w, section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTable writes a header and rows with aligned columns and returns the
+// number of trailing columns left out to fit the table into r.width.
+func (r tableRenderer) writeTable(w io.Writer, header []string, rows [][]string) int {
+	widths := make([]int, len(header))
+	for i, name := range header {
+		widths[i] = displayWidth(name)
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			widths[i] = max(widths[i], displayWidth(value))
+		}
+	}
+	if r.width > 0 {
+		widths = fitColumns(header, widths, r.width)
+	}
+
+	writeRow(w, header, widths)
+	if r.decorate {
+		separator := make([]string, len(widths))
+		for i, width := range widths {
+			separator[i] = strings.Repeat("-", min(displayWidth(header[i]), width))
+		}
+		writeRow(w, separator, widths)
+	}
+	for _, row := range rows {
+		writeRow(w, row, widths)
+	}
+	return len(header) - len(widths)
+}
+
+// writeRow writes the cells of a row padded to the column widths, wrapping
+// cells wider than their column onto further lines. Cells beyond the last
+// width are left out.
+func writeRow(w io.Writer, cells []string, widths []int) {
+	lines := make([][]string, len(widths))
+	height := 1
+	for i, width := range widths {
+		if i < len(cells) {
+			lines[i] = wrapText(cells[i], width)
+		}
+		height = max(height, len(lines[i]))
+	}
+
+	var b strings.Builder
+	for l := 0; l < height; l++ {
+		b.Reset()
+		for i, width := range widths {
+			text := ""
+			if l < len(lines[i]) {
+				text = lines[i][l]
+			}
+			b.WriteString(text)
+			if i < len(widths)-1 {
+				b.WriteString(strings.Repeat(" ", max(width-displayWidth(text), 0)+columnGap))
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+	}
+}
+
+// fitColumns shrinks column widths so that a table fits into width terminal
+// cells. Columns narrower than an even share of the space keep their width
+// and the others split the rest. Columns are never made narrower than their
+// header, up to 16 cells; trailing columns are left out when even that does
+// not fit, so the returned slice may be shorter than natural.
+func fitColumns(header []string, natural []int, width int) []int {
+	minimum := make([]int, len(natural))
+	for i, name := range header {
+		minimum[i] = min(natural[i], max(min(displayWidth(name), 16), 6))
+	}
+
+	count := len(natural)
+	for ; count > 1; count-- {
+		total := columnGap * (count - 1)
+		for _, m := range minimum[:count] {
+			total += m
+		}
+		if total <= width {
+			break
+		}
+	}
+
+	widths := append([]int(nil), natural[:count]...)
+	fixed := make([]bool, count)
+	available := width - columnGap*(count-1)
+	for {
+		rest, flexible := available, 0
+		for i := range widths {
+			if fixed[i] {
+				rest -= widths[i]
+			} else {
+				flexible++
+			}
+		}
+		if flexible == 0 {
+			break
+		}
+		share := rest / flexible
+		changed := false
+		for i := range widths {
+			switch {
+			case fixed[i]:
+			case natural[i] <= share:
+				widths[i], fixed[i], changed = natural[i], true, true
+			case minimum[i] >= share:
+				widths[i], fixed[i], changed = minimum[i], true, true
+			}
+		}
+		if !changed {
+			extra := rest - share*flexible
+			for i := range widths {
+				if !fixed[i] {
+					widths[i] = share
+					if extra > 0 {
+						widths[i]++
+						extra--
+					}
+				}
+			}
+			break
+		}
+	}
+	for i := range widths {
+		widths[i] = max(widths[i], 1)
+	}
+	return widths
+}
+
+// delimitedRenderer writes every column of every table as CSV or TSV.
+type delimitedRenderer struct {
+	comma rune
+}
+
+func (r delimitedRenderer) Render(w io.Writer, v *View) error {
+	if len(v.Columns) == 0 && v.Message != "" && len(v.Sections) == 0 {
+		_, err := fmt.Fprintln(w, v.Message)
+		return err
+	}
+
+	records := [][]string{}
+	if len(v.Columns) > 0 {
+		header := make([]string, len(v.Columns))
+		for i, col := range v.Columns {
+			header[i] = col.Name
+		}
+		records = append(records, header)
+		for _, row := range v.Rows {
+			record := make([]string, len(v.Columns))
+			copy(record, row)
+			records = append(records, record)
+		}
+	}
+
+	if r.comma == '\t' {
+		for _, record := range records {
+			for i, field := range record {
+				record[i] = escapeTSV(field)
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(record, "\t")); err != nil {
+				return err
+			}
+		}
+	} else {
+		cw := csv.NewWriter(w)
+		cw.Comma = r.comma
+		if err := cw.WriteAll(records); err != nil {
+			return err
+		}
+	}
+
+	for i, section := range v.Sections {
+		if i > 0 || len(records) > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := r.Render(w, section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+func escapeTSV(s string) string {
+	return tsvEscaper.Replace(s)
+}
+
+// jsonRenderer writes the structured form of a view as indented JSON.
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, v *View) error {
+	data, err := json.MarshalIndent(v.structured(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// yamlRenderer writes the structured form of a view as YAML, using the same
+// keys as the JSON output.
+type yamlRenderer struct{}
+
+func (yamlRenderer) Render(w io.Writer, v *View) error {
+	generic, err := toGeneric(v.structured())
+	if err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(generic); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// ndjsonRenderer writes one compact JSON record per line.
+type ndjsonRenderer struct{}
+
+func (ndjsonRenderer) Render(w io.Writer, v *View) error {
+	for _, item := range v.items() {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toGeneric converts a value to maps, slices and numbers through its JSON
+// encoding, so that other encoders honour the json struct tags. Integers are
+// kept as int64 rather than float64.
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return convertNumbers(generic), nil
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = convertNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = convertNumbers(item)
+		}
+	}
+	return v
+}
+
+// sortedKeys returns the keys of a map in sorted order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
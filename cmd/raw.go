@@ -0,0 +1,117 @@
+// cmd/raw.go
+
+package main
+
+import (
+	"bytes"
+	"dcli/api"
+	"dcli/utils"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// rawCommand sends a request to any endpoint of the server, such as
+// /aggregate or /_config, with the configured base URL and credentials.
+func rawCommand(client *api.Client, name string, args []string) {
+	rawCmd := flag.NewFlagSet(name, flag.ExitOnError)
+	registerGlobalFlags(rawCmd)
+	var queries, headers stringList
+	rawCmd.Var(&queries, "q", "Add a query parameter, as key=value (repeatable)")
+	rawCmd.Var(&headers, "H", "Add or replace a request header, as 'Name: value' (repeatable)")
+	data := rawCmd.String("d", "", "Request body: the text itself, @file to read it from a file or @- for stdin")
+	include := rawCmd.Bool("i", false, "Print the response status and headers to stderr")
+	positional := parseInterspersed(rawCmd, args)
+
+	var method, path string
+	switch len(positional) {
+	case 1:
+		// Like curl, send a body with POST unless told otherwise
+		method, path = http.MethodGet, positional[0]
+		if *data != "" {
+			method = http.MethodPost
+		}
+	case 2:
+		method, path = strings.ToUpper(positional[0]), positional[1]
+	default:
+		fmt.Printf("Usage: dcli %s [METHOD] <path> [-q key=value] [-d @body.json] [-H 'Name: value']\n", name)
+		rawCmd.Usage()
+		os.Exit(1)
+	}
+
+	query := url.Values{}
+	for _, q := range queries {
+		key, value, _ := strings.Cut(q, "=")
+		if key == "" {
+			utils.ErrorLogger.Printf("Invalid query parameter %q: expected key=value", q)
+			os.Exit(1)
+		}
+		query.Add(key, value)
+	}
+	header := http.Header{}
+	for _, h := range headers {
+		key, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			utils.ErrorLogger.Printf("Invalid header %q: expected 'Name: value'", h)
+			os.Exit(1)
+		}
+		header.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	var body []byte
+	if *data != "" {
+		body = []byte(*data)
+		if strings.HasPrefix(*data, "@") {
+			var err error
+			if body, err = readInput(strings.TrimPrefix(*data, "@")); err != nil {
+				utils.ErrorLogger.Println("Failed to read request body:", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	resp, err := client.Raw(method, path, query, header, body)
+	if isDryRun(err) {
+		return
+	}
+	if err != nil {
+		utils.ErrorLogger.Println("Request failed:", err)
+		os.Exit(1)
+	}
+
+	if *include {
+		fmt.Fprintln(os.Stderr, resp.Status)
+		names := make([]string, 0, len(resp.Header))
+		for name := range resp.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, strings.Join(resp.Header[name], ", "))
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+	writeRawBody(resp)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		utils.ErrorLogger.Println("Request failed:", resp.Status)
+		os.Exit(1)
+	}
+}
+
+// writeRawBody prints a JSON response indented, in any of the output
+// formats, and anything else as it came.
+func writeRawBody(resp *api.RawResponse) {
+	var decoded interface{}
+	if len(bytes.TrimSpace(resp.Body)) == 0 || json.Unmarshal(resp.Body, &decoded) != nil {
+		os.Stdout.Write(resp.Body)
+		return
+	}
+	var indented bytes.Buffer
+	json.Indent(&indented, resp.Body, "", "  ")
+	render(&View{Message: strings.TrimSpace(indented.String()), Data: decoded})
+}
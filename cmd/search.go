@@ -0,0 +1,176 @@
+// cmd/search.go
+
+package main
+
+import (
+	"dcli/api"
+	"dcli/utils"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// searchHit is a resource that matched the search text in one or more columns.
+// Value is the value of the first matched column, by name.
+type searchHit struct {
+	Entity  string   `json:"entity"`
+	ID      string   `json:"id"`
+	Columns []string `json:"columns"`
+	Value   string   `json:"value"`
+
+	values map[string]string // By matched column
+}
+
+func searchCommand(client *api.Client, args []string) {
+	searchCmd := flag.NewFlagSet("search", flag.ExitOnError)
+	registerGlobalFlags(searchCmd)
+	types := searchCmd.String("types", "", "Comma-separated entity types to search (default: all entities)")
+	limit := searchCmd.Int("limit", 10, "Maximum number of matches per entity column")
+	concurrency := searchCmd.Int("concurrency", 8, "Number of queries to run at the same time")
+	positional := parseInterspersed(searchCmd, args)
+
+	if len(positional) != 1 || positional[0] == "" {
+		fmt.Println("Usage: dcli search <text> [-types a,b]")
+		searchCmd.Usage()
+		os.Exit(1)
+	}
+	text := positional[0]
+
+	var entities []string
+	if *types != "" {
+		for _, name := range strings.Split(*types, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				entities = append(entities, name)
+			}
+		}
+	} else {
+		var err error
+		entities, err = client.ListEntities()
+		if err != nil {
+			utils.ErrorLogger.Println("Failed to discover entities:", err)
+			os.Exit(1)
+		}
+	}
+
+	// Discover the searchable columns of every entity
+	type target struct {
+		entity string
+		column string
+	}
+	columnsByEntity := make([][]string, len(entities))
+	forEachConcurrently(len(entities), *concurrency, func(i int) {
+		model, err := client.GetEntityModel(entities[i])
+		if err != nil {
+			utils.ErrorLogger.Printf("Skipping %s: %v", entities[i], err)
+			return
+		}
+		columnsByEntity[i] = model.SearchableColumns()
+	})
+
+	var targets []target
+	for i, columns := range columnsByEntity {
+		for _, column := range columns {
+			targets = append(targets, target{entity: entities[i], column: column})
+		}
+	}
+
+	// Query every searchable column
+	var mu sync.Mutex
+	hits := make(map[string]*searchHit)
+	forEachConcurrently(len(targets), *concurrency, func(i int) {
+		t := targets[i]
+		options := &api.ListOptions{
+			Page:  map[string]string{"size": strconv.Itoa(*limit)},
+			Query: []api.Condition{{Column: t.column, Operator: "like", Value: "%" + text + "%"}},
+		}
+		doc, err := client.List(t.entity, options)
+		if err != nil {
+			utils.ErrorLogger.Printf("Failed to search %s.%s: %v", t.entity, t.column, err)
+			return
+		}
+		resources, err := doc.Resolve()
+		if err != nil {
+			utils.ErrorLogger.Printf("Failed to parse %s results: %v", t.entity, err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, res := range resources {
+			key := t.entity + "/" + res.ID
+			hit, ok := hits[key]
+			if !ok {
+				hit = &searchHit{Entity: t.entity, ID: res.ID, values: make(map[string]string)}
+				hits[key] = hit
+			}
+			hit.Columns = append(hit.Columns, t.column)
+			hit.values[t.column] = fmt.Sprintf("%v", res.Attributes[t.column])
+		}
+	})
+
+	displaySearchHits(sortedSearchHits(hits))
+}
+
+func sortedSearchHits(hits map[string]*searchHit) []*searchHit {
+	list := make([]*searchHit, 0, len(hits))
+	for _, hit := range hits {
+		sort.Strings(hit.Columns)
+		if len(hit.Columns) > 0 {
+			hit.Value = hit.values[hit.Columns[0]]
+		}
+		list = append(list, hit)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Entity != list[j].Entity {
+			return list[i].Entity < list[j].Entity
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+func displaySearchHits(hits []*searchHit) {
+	if len(hits) == 0 {
+		render(&View{Message: "No matches found.", Data: hits})
+		return
+	}
+
+	view := &View{
+		Columns: columnNames("Entity", "ID", "Matched Column", "Value"),
+		Data:    hits,
+	}
+	previous := ""
+	for _, hit := range hits {
+		entity := hit.Entity
+		if entity == previous && isTableOutput() {
+			entity = "" // Group rows of the same entity
+		}
+		previous = hit.Entity
+		view.Rows = append(view.Rows, []string{entity, hit.ID, strings.Join(hit.Columns, ","), hit.Value})
+	}
+	render(view)
+}
+
+// forEachConcurrently calls fn for every index below count, running at most
+// limit calls at the same time.
+func forEachConcurrently(count, limit int, fn func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, limit)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,21 @@
+// cmd/search_test.go
+
+package main
+
+import "testing"
+
+// The value shown for a hit is the one of its first matched column, whichever
+// column's query answered first.
+func TestSortedSearchHitsValue(t *testing.T) {
+	for _, order := range [][]string{{"email", "name"}, {"name", "email"}} {
+		hit := &searchHit{Entity: "user_account", ID: "u1", values: make(map[string]string)}
+		for _, column := range order {
+			hit.Columns = append(hit.Columns, column)
+			hit.values[column] = column + " value"
+		}
+		hits := sortedSearchHits(map[string]*searchHit{"user_account/u1": hit})
+		if len(hits) != 1 || hits[0].Value != "email value" || hits[0].Columns[0] != "email" {
+			t.Errorf("found in %v: got %+v", order, hits[0])
+		}
+	}
+}
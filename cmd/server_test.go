@@ -0,0 +1,340 @@
+// cmd/server_test.go
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"dcli/api"
+	"dcli/utils"
+)
+
+// fakeRecord is a record held by fakeServer. Relationships hold linkage as
+// decoded from JSON: an identifier object, a list of them, or nil.
+type fakeRecord struct {
+	Attributes    map[string]interface{}
+	Relationships map[string]interface{}
+}
+
+// fakeServer is an in-memory daptin serving the JSON:API endpoints the
+// commands use. Like daptin, it only returns the linkage of relations named
+// in included_relations; other relations only carry links. Every PATCH of a
+// record increments its version.
+type fakeServer struct {
+	t      *testing.T
+	client *api.Client
+	url    string // Base URL, without a trailing slash
+	home   string // HOME of the dcli processes started by run
+
+	mu       sync.Mutex
+	records  map[string]map[string]*fakeRecord // By type and ID
+	models   map[string]string                 // Entity models by type, as served at /jsmodel/<type>.js
+	requests []string                          // "METHOD path" of every request
+	nextID   int
+
+	// before, when set, is called with every request before it is handled,
+	// without the lock held.
+	before func(r *http.Request)
+	// drop, when set, makes the server close the connection without an
+	// answer to the requests it returns true for.
+	drop func(r *http.Request) bool
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	utils.InitLogger(false)
+	f := &fakeServer{t: t, records: make(map[string]map[string]*fakeRecord), models: make(map[string]string)}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+
+	client, err := api.NewClient(server.URL+"/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.client = client
+	f.url = server.URL
+
+	f.home = t.TempDir()
+	if err := utils.SaveConfig(&utils.Config{BaseURL: server.URL + "/"}, filepath.Join(f.home, ".dcli", "config.json")); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// add stores a record at version 1 and returns it.
+func (f *fakeServer) add(resourceType, id string, attributes map[string]interface{}, relationships map[string]interface{}) *fakeRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record := &fakeRecord{Attributes: map[string]interface{}{"version": float64(1)}, Relationships: map[string]interface{}{}}
+	for name, value := range attributes {
+		record.Attributes[name] = value
+	}
+	for name, linkage := range relationships {
+		record.Relationships[name] = linkage
+	}
+	if f.records[resourceType] == nil {
+		f.records[resourceType] = make(map[string]*fakeRecord)
+	}
+	f.records[resourceType][id] = record
+	return record
+}
+
+// record returns a stored record, or nil.
+func (f *fakeServer) record(resourceType, id string) *fakeRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.records[resourceType][id]
+}
+
+// sent returns the requests received so far with the given method.
+func (f *fakeServer) sent(method string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, method+" ") {
+			out = append(out, request)
+		}
+	}
+	return out
+}
+
+func (f *fakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	if f.before != nil {
+		f.before(r)
+	}
+	if f.drop != nil && f.drop(r) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			f.t.Errorf("fake server: %v", err)
+			return
+		}
+		conn.Close()
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+
+	if strings.HasPrefix(r.URL.Path, "/jsmodel/") {
+		model, ok := f.models[strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/jsmodel/"), ".js")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "no such model")
+			return
+		}
+		io.WriteString(w, model)
+		return
+	}
+
+	// /api/<type>[/<id>[/relationships/<name>]]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+	resourceType := parts[0]
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			f.list(w, r, resourceType)
+		case http.MethodPost:
+			f.create(w, resourceType, body)
+		default:
+			f.fail(w, http.StatusMethodNotAllowed, r.Method)
+		}
+		return
+	}
+
+	id := parts[1]
+	record := f.records[resourceType][id]
+	if record == nil {
+		f.fail(w, http.StatusNotFound, resourceType+" "+id+" not found")
+		return
+	}
+	if len(parts) == 4 && parts[2] == "relationships" {
+		name := parts[3]
+		switch r.Method {
+		case http.MethodGet:
+			f.write(w, map[string]interface{}{"data": record.Relationships[name]})
+		case http.MethodPatch:
+			var doc struct {
+				Data interface{} `json:"data"`
+			}
+			json.Unmarshal(body, &doc)
+			record.Relationships[name] = doc.Data
+			f.write(w, map[string]interface{}{"data": doc.Data})
+		default:
+			f.fail(w, http.StatusMethodNotAllowed, r.Method)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		f.write(w, map[string]interface{}{"data": f.resource(resourceType, id, record, r.URL.Query().Get("included_relations"))})
+	case http.MethodPatch:
+		var doc struct {
+			Data fakeRecord `json:"data"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			f.fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for name, value := range doc.Data.Attributes {
+			record.Attributes[name] = value
+		}
+		for name, rel := range doc.Data.Relationships {
+			record.Relationships[name] = rel.(map[string]interface{})["data"]
+		}
+		version, _ := record.Attributes["version"].(float64)
+		record.Attributes["version"] = version + 1
+		f.write(w, map[string]interface{}{"data": f.resource(resourceType, id, record, "")})
+	case http.MethodDelete:
+		delete(f.records[resourceType], id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, http.StatusMethodNotAllowed, r.Method)
+	}
+}
+
+// list serves the records of a type matching the eq conditions of the query
+// parameter, ordered by ID.
+func (f *fakeServer) list(w http.ResponseWriter, r *http.Request, resourceType string) {
+	var conditions []api.Condition
+	if query := r.URL.Query().Get("query"); query != "" {
+		data, _ := base64.StdEncoding.DecodeString(query)
+		if err := json.Unmarshal(data, &conditions); err != nil {
+			f.fail(w, http.StatusBadRequest, "invalid query")
+			return
+		}
+	}
+	ids := make([]string, 0, len(f.records[resourceType]))
+	for id := range f.records[resourceType] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	data := []interface{}{}
+	for _, id := range ids {
+		record := f.records[resourceType][id]
+		match := true
+		for _, condition := range conditions {
+			if condition.Operator != "eq" || fmt.Sprint(record.Attributes[condition.Column]) != fmt.Sprint(condition.Value) {
+				match = false
+			}
+		}
+		if match {
+			data = append(data, f.resource(resourceType, id, record, r.URL.Query().Get("included_relations")))
+		}
+	}
+	f.write(w, map[string]interface{}{"data": data})
+}
+
+func (f *fakeServer) create(w http.ResponseWriter, resourceType string, body []byte) {
+	var doc struct {
+		Data fakeRecord `json:"data"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		f.fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f.nextID++
+	id := fmt.Sprintf("%s-%d", resourceType, f.nextID)
+	record := &fakeRecord{Attributes: map[string]interface{}{"version": float64(1)}, Relationships: map[string]interface{}{}}
+	for name, value := range doc.Data.Attributes {
+		record.Attributes[name] = value
+	}
+	for name, rel := range doc.Data.Relationships {
+		record.Relationships[name] = rel.(map[string]interface{})["data"]
+	}
+	if f.records[resourceType] == nil {
+		f.records[resourceType] = make(map[string]*fakeRecord)
+	}
+	f.records[resourceType][id] = record
+	w.WriteHeader(http.StatusCreated)
+	f.write(w, map[string]interface{}{"data": f.resource(resourceType, id, record, "")})
+}
+
+// resource encodes a record as a resource object, with the linkage of the
+// comma-separated included relations.
+func (f *fakeServer) resource(resourceType, id string, record *fakeRecord, include string) map[string]interface{} {
+	attributes := map[string]interface{}{"reference_id": id}
+	for name, value := range record.Attributes {
+		attributes[name] = value
+	}
+	included := make(map[string]bool)
+	for _, name := range strings.Split(include, ",") {
+		included[name] = true
+	}
+	relationships := make(map[string]interface{}, len(record.Relationships))
+	for name, linkage := range record.Relationships {
+		rel := map[string]interface{}{"links": map[string]string{"related": fmt.Sprintf("/api/%s/%s/%s", resourceType, id, name)}}
+		if included[name] {
+			rel["data"] = linkage
+		}
+		relationships[name] = rel
+	}
+	return map[string]interface{}{"type": resourceType, "id": id, "attributes": attributes, "relationships": relationships}
+}
+
+func (f *fakeServer) write(w http.ResponseWriter, v interface{}) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		f.t.Errorf("fake server: %v", err)
+	}
+}
+
+func (f *fakeServer) fail(w http.ResponseWriter, status int, detail string) {
+	w.WriteHeader(status)
+	f.write(w, map[string]interface{}{"errors": []map[string]string{{"status": fmt.Sprint(status), "title": http.StatusText(status), "detail": detail}}})
+}
+
+// TestMain runs the command line in DCLI_TEST_ARGS instead of the tests
+// when it is set, so that tests can run dcli as a process with fakeServer.run
+// and see its exit code.
+func TestMain(m *testing.M) {
+	if args := os.Getenv("DCLI_TEST_ARGS"); args != "" {
+		os.Args = []string{"dcli"}
+		if err := json.Unmarshal([]byte(args), &os.Args); err != nil {
+			fmt.Fprintln(os.Stderr, "DCLI_TEST_ARGS:", err)
+			os.Exit(2)
+		}
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run runs dcli with the given arguments against the server, in a home
+// directory of its own holding the configuration and journal, and returns
+// its output and exit code.
+func (f *fakeServer) run(stdin string, args ...string) (stdout, stderr string, code int) {
+	f.t.Helper()
+	encoded, _ := json.Marshal(append([]string{"dcli"}, args...))
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), "DCLI_TEST_ARGS="+string(encoded), "HOME="+f.home, "PAGER=cat")
+	cmd.Dir = f.home
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut strings.Builder
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		f.t.Fatalf("dcli %s: %v", strings.Join(args, " "), err)
+	}
+	return out.String(), errOut.String(), code
+}
@@ -0,0 +1,241 @@
+// cmd/set.go
+
+package main
+
+import (
+	"dcli/api"
+	"dcli/models"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stringList is a flag that may be repeated, collecting every value.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// assignment is one attribute given with --set or --set-file.
+type assignment struct {
+	column string
+	value  string
+	raw    bool // value is JSON, from --set column:=value
+	file   bool // value is a file name, from --set-file
+}
+
+// parseAssignments parses --set values of the form column=value or
+// column:=json and --set-file values of the form column=@path.
+func parseAssignments(sets, setFiles []string) ([]assignment, error) {
+	var assignments []assignment
+	for _, set := range sets {
+		column, value, ok := strings.Cut(set, "=")
+		if !ok || column == "" || column == ":" {
+			return nil, fmt.Errorf("invalid --set %q, expected column=value or column:=json", set)
+		}
+		a := assignment{column: column, value: value}
+		if strings.HasSuffix(column, ":") {
+			a.column, a.raw = strings.TrimSuffix(column, ":"), true
+		}
+		assignments = append(assignments, a)
+	}
+	for _, set := range setFiles {
+		column, path, ok := strings.Cut(set, "=")
+		path = strings.TrimPrefix(path, "@")
+		if !ok || column == "" || path == "" {
+			return nil, fmt.Errorf("invalid --set-file %q, expected column=@path", set)
+		}
+		assignments = append(assignments, assignment{column: column, value: path, file: true})
+	}
+	return assignments, nil
+}
+
+// assignmentValue returns the attribute value of an assignment, coerced to
+// the type of its column.
+func assignmentValue(a assignment, model *api.TableInfo) (interface{}, error) {
+	col, ok := model.ColumnModel[a.column]
+	if !ok {
+		return nil, fmt.Errorf("unknown column %q of %s, available columns: %s",
+			a.column, model.TableName, strings.Join(attributeNames(model), ", "))
+	}
+	if col.JsonApi != "" {
+		return nil, fmt.Errorf("%q is a %s relation of %s, not an attribute; link it with --rel", a.column, col.JsonApi, model.TableName)
+	}
+
+	if a.raw {
+		var value interface{}
+		if err := json.Unmarshal([]byte(a.value), &value); err != nil {
+			return nil, fmt.Errorf("invalid JSON for %s: %w", a.column, err)
+		}
+		return value, nil
+	}
+
+	text := a.value
+	if a.file {
+		data, err := readInput(a.value)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(col.ColumnType, "file") {
+			return fileValue(a.value, data), nil
+		}
+		text = string(data)
+	}
+
+	value, err := coerceValue(text, col)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s (%s): %w", a.column, columnTypeName(col), err)
+	}
+	return value, nil
+}
+
+// attributeNames returns the sorted names of the attribute columns of a model.
+func attributeNames(model *api.TableInfo) []string {
+	var names []string
+	for name, col := range model.ColumnModel {
+		if col.JsonApi == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// relationAssignment is a relationship given with --rel.
+type relationAssignment struct {
+	relation   string
+	targetType string
+	ids        string // Comma-separated reference IDs
+}
+
+// parseRelationAssignments parses --rel values of the form
+// relation=type:id or relation=type:id1,id2.
+func parseRelationAssignments(rels []string) ([]relationAssignment, error) {
+	var assignments []relationAssignment
+	for _, rel := range rels {
+		relation, target, ok := strings.Cut(rel, "=")
+		targetType, ids, hasType := strings.Cut(target, ":")
+		if !ok || !hasType || relation == "" || targetType == "" || strings.Trim(ids, ", ") == "" {
+			return nil, fmt.Errorf("invalid --rel %q, expected relation=type:id or relation=type:id1,id2", rel)
+		}
+		assignments = append(assignments, relationAssignment{relation: relation, targetType: targetType, ids: ids})
+	}
+	return assignments, nil
+}
+
+// relationshipValue returns the linkage of a relation assignment, checked
+// against the relations of the model.
+func relationshipValue(a relationAssignment, model *api.TableInfo) (models.Relationship, error) {
+	col, ok := model.ColumnModel[a.relation]
+	if !ok || col.JsonApi == "" || col.ExcludeFromApi {
+		return models.Relationship{}, fmt.Errorf("unknown relation %q of %s, available relations: %s",
+			a.relation, model.TableName, strings.Join(relationNames(model), ", "))
+	}
+	if col.Type != "" && col.Type != a.targetType {
+		return models.Relationship{}, fmt.Errorf("relation %s of %s links to %s records, not %s", a.relation, model.TableName, col.Type, a.targetType)
+	}
+	if col.JsonApi != "hasMany" && strings.Contains(strings.Trim(a.ids, ", "), ",") {
+		return models.Relationship{}, fmt.Errorf("relation %s of %s is %s and links to a single record, got %s", a.relation, model.TableName, col.JsonApi, a.ids)
+	}
+	col.Type = a.targetType
+	return models.Relationship{Data: referenceLinkage(a.ids, col)}, nil
+}
+
+// relationNames returns the sorted names of the relations of a model.
+func relationNames(model *api.TableInfo) []string {
+	var names []string
+	for name, col := range model.ColumnModel {
+		if col.JsonApi != "" && !col.ExcludeFromApi {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func columnTypeName(col api.ColumnInfo) string {
+	if col.DataType == "" {
+		return col.ColumnType
+	}
+	return col.ColumnType + ", " + col.DataType
+}
+
+// readInput reads a file, or stdin when path is "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// fileValue encodes file contents the way daptin expects for file columns:
+// a list of files with their name, MIME type and base64 data URI.
+func fileValue(path string, data []byte) []interface{} {
+	name := filepath.Base(path)
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if path == "-" {
+		name = "stdin"
+	}
+	return []interface{}{map[string]interface{}{
+		"name": name,
+		"type": mimeType,
+		"file": "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data),
+	}}
+}
+
+// coerceValue converts the text of a --set value to the JSON type of its
+// column, judged by the column type and the database data type.
+func coerceValue(text string, col api.ColumnInfo) (interface{}, error) {
+	dataType := strings.ToLower(col.DataType)
+	if i := strings.Index(dataType, "("); i >= 0 {
+		dataType = dataType[:i]
+	}
+
+	switch {
+	case col.ColumnType == "truefalse" || dataType == "bool" || dataType == "boolean":
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false, got %q", text)
+		}
+		return value, nil
+	case col.ColumnType == "json":
+		var value interface{}
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			return nil, fmt.Errorf("expected JSON: %w", err)
+		}
+		return value, nil
+	}
+
+	switch dataType {
+	case "int", "integer", "tinyint", "smallint", "mediumint", "bigint":
+		value, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer, got %q", text)
+		}
+		return value, nil
+	case "float", "double", "decimal", "numeric", "real":
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number, got %q", text)
+		}
+		return value, nil
+	}
+	return text, nil
+}
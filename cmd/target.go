@@ -0,0 +1,87 @@
+// cmd/target.go
+
+package main
+
+import (
+	"dcli/api"
+	"dcli/models"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// targetCandidates is the number of matching records listed when -by
+// matches more than one.
+const targetCandidates = 10
+
+// registerByFlag adds -by, which names the record a command acts on by
+// conditions only it matches instead of by ID. With where, -where is
+// accepted as another name for it.
+func registerByFlag(fs *flag.FlagSet, where bool) *string {
+	usage := "Find the record by conditions only it matches instead of by ID, e.g. email=alice@x.com (operators: = != > >= < <= ~)"
+	by := fs.String("by", "", usage)
+	if where {
+		fs.StringVar(by, "where", "", "Same as -by")
+	}
+	return by
+}
+
+// resolveID sets id to the ID of the one record of a type matching the -by
+// conditions, when they are given. It fails when no record or more than one
+// matches, listing the candidates.
+func resolveID(client *api.Client, resourceType string, id *string, by string) error {
+	if by == "" {
+		return nil
+	}
+	if *id != "" {
+		return fmt.Errorf("give either -id or -by, not both")
+	}
+	if resourceType == "" {
+		return fmt.Errorf("-by needs -type")
+	}
+	conditions, err := api.ParseWhere(by)
+	if err != nil {
+		return err
+	}
+
+	options := &api.ListOptions{
+		Page:  map[string]string{"size": fmt.Sprint(targetCandidates)},
+		Query: conditions,
+	}
+	doc, err := client.List(resourceType, options)
+	if err != nil {
+		return err
+	}
+	resources, err := doc.Resolve()
+	if err != nil {
+		return err
+	}
+	total := len(resources)
+	if doc.Links != nil && doc.Links.Total > total {
+		total = doc.Links.Total
+	}
+
+	switch {
+	case total == 0:
+		return fmt.Errorf("no %s record matches %s", resourceType, by)
+	case total == 1:
+		*id = resources[0].ID
+		return nil
+	}
+	return ambiguousTarget(resourceType, by, total, resources)
+}
+
+// ambiguousTarget describes the records matching conditions meant to find
+// a single one.
+func ambiguousTarget(resourceType, by string, total int, candidates []*models.Resource) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s records match %s; add conditions to pick one, or use -id:", total, resourceType, by)
+	for _, res := range candidates {
+		fmt.Fprintf(&b, "\n  %s", recordLabel(res))
+	}
+	if total > len(candidates) {
+		fmt.Fprintf(&b, "\n  … and %d more", total-len(candidates))
+	}
+	return errors.New(b.String())
+}
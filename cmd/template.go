@@ -0,0 +1,132 @@
+// cmd/template.go
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateRenderer executes a Go template once per record of a view, e.g.
+// '{{.ID}} {{.Attributes.email}}' for every resource of a list.
+type templateRenderer struct {
+	template *template.Template
+}
+
+func newTemplateRenderer(text string) (Renderer, error) {
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return templateRenderer{template: tmpl}, nil
+}
+
+func (r templateRenderer) Render(w io.Writer, v *View) error {
+	records := v.Values
+	if records == nil {
+		records = v.items()
+	}
+	for _, record := range records {
+		var out strings.Builder
+		if err := r.template.Execute(&out, record); err != nil {
+			return err
+		}
+		text := out.String()
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		if _, err := io.WriteString(w, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateFuncs are the helper functions available to output templates.
+var templateFuncs = template.FuncMap{
+	"date":    formatDate,
+	"join":    joinValues,
+	"default": defaultValue,
+	"json":    toJSON,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+}
+
+// dateLayouts are the timestamp formats daptin uses in attributes.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// formatDate formats a timestamp attribute with a Go time layout, e.g.
+// {{date "2006-01-02" .Attributes.created_at}}. Values that are not
+// timestamps are returned unchanged.
+func formatDate(layout string, value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout)
+	case float64:
+		return time.Unix(int64(v), 0).Format(layout)
+	case int64:
+		return time.Unix(v, 0).Format(layout)
+	case string:
+		for _, candidate := range dateLayouts {
+			if t, err := time.Parse(candidate, v); err == nil {
+				return t.Format(layout)
+			}
+		}
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(seconds, 0).Format(layout)
+		}
+		return v
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// joinValues joins the items of a list with a separator, e.g.
+// {{join ", " .Attributes.tags}}.
+func joinValues(separator string, value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Sprintf("%v", value)
+	}
+	items := make([]string, rv.Len())
+	for i := range items {
+		items[i] = fmt.Sprintf("%v", rv.Index(i).Interface())
+	}
+	return strings.Join(items, separator)
+}
+
+// defaultValue returns fallback when value is missing or empty, e.g.
+// {{.Attributes.name | default "unnamed"}}.
+func defaultValue(fallback interface{}, value interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if rv.Len() == 0 {
+			return fallback
+		}
+	}
+	return value
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
@@ -0,0 +1,101 @@
+// cmd/terminal.go
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// terminalInfo describes the terminal stdout is attached to, if any.
+type terminalInfo struct {
+	isTTY  bool
+	width  int
+	height int
+}
+
+// stdoutTerminal detects whether stdout is a terminal and its size. $COLUMNS
+// and $LINES override the detected size.
+func stdoutTerminal() terminalInfo {
+	fd := int(os.Stdout.Fd())
+	info := terminalInfo{isTTY: term.IsTerminal(fd)}
+	if !info.isTTY {
+		return info
+	}
+	if width, height, err := term.GetSize(fd); err == nil {
+		info.width, info.height = width, height
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		info.width = columns
+	}
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		info.height = lines
+	}
+	return info
+}
+
+// stderrIsTerminal reports whether stderr, where prompts and progress go, is
+// a terminal.
+func stderrIsTerminal() bool {
+	return term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// writeOutput writes command output to stdout, through $PAGER when stdout
+// is a terminal and the output is taller than it. Without $PAGER, less is
+// used when available.
+func writeOutput(out []byte, terminal terminalInfo) {
+	if !terminal.isTTY || globalOptions.noPager || terminal.height == 0 ||
+		bytes.Count(out, []byte("\n")) < terminal.height {
+		os.Stdout.Write(out)
+		return
+	}
+
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		if _, err := exec.LookPath("less"); err == nil {
+			pager = []string{"less", "-FRX"}
+		}
+	}
+	if len(pager) == 0 || pager[0] == "cat" {
+		os.Stdout.Write(out)
+		return
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = bytes.NewReader(out)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		os.Stdout.Write(out)
+		return
+	}
+	cmd.Wait()
+}
+
+// ANSI escape sequences for colored terminal output.
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// useColor reports whether output may be colored: stdout is a terminal and
+// $NO_COLOR is not set.
+func (t terminalInfo) useColor() bool {
+	return t.isTTY && os.Getenv("NO_COLOR") == ""
+}
+
+// colorize wraps s in an ANSI color when enabled.
+func colorize(enabled bool, color, s string) string {
+	if !enabled {
+		return s
+	}
+	return color + s + colorReset
+}
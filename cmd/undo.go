@@ -0,0 +1,184 @@
+// cmd/undo.go
+
+package main
+
+import (
+	"dcli/api"
+	"dcli/utils"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func historyCommand(client *api.Client, args []string) {
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+	registerGlobalFlags(historyCmd)
+	limit := historyCmd.Int("n", 20, "Number of changes to show, newest first; 0 for all")
+	resourceType := historyCmd.String("type", "", "Only show changes to this resource type")
+	id := historyCmd.String("id", "", "Only show changes to the resource with this ID")
+	historyCmd.Parse(args)
+
+	j := client.Recorder.(*journal)
+	entries, err := readJournal(j.path)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to read the journal:", err)
+		os.Exit(1)
+	}
+	undone := undoneEntries(entries)
+
+	view := &View{
+		Title:   "History",
+		Columns: columnNames("ID", "Time", "Kind", "Type", "Resource", "Change", "Undone"),
+		Data:    []*journalEntry{},
+	}
+	var shown []*journalEntry
+	for i := len(entries) - 1; i >= 0 && (*limit <= 0 || len(shown) < *limit); i-- {
+		entry := entries[i]
+		if (*resourceType != "" && entry.Type != *resourceType) || (*id != "" && entry.ResourceID != *id) {
+			continue
+		}
+		shown = append(shown, entry)
+		undoneBy := ""
+		if by, ok := undone[entry.ID]; ok {
+			undoneBy = fmt.Sprintf("by #%d", by)
+		}
+		view.Rows = append(view.Rows, []string{
+			strconv.Itoa(entry.ID),
+			entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Kind,
+			entry.Type,
+			entry.ResourceID,
+			entry.describe(),
+			undoneBy,
+		})
+	}
+	if len(shown) > 0 {
+		view.Data = shown
+	} else {
+		view.Notes = append(view.Notes, "No changes recorded in "+j.path)
+	}
+	render(view)
+}
+
+func undoCommand(client *api.Client, args []string) {
+	undoCmd := flag.NewFlagSet("undo", flag.ExitOnError)
+	registerGlobalFlags(undoCmd)
+	run := undoCmd.Bool("run", false, "Undo every change made by the same command as the given one")
+	yes := undoCmd.Bool("yes", false, "Undo without asking for confirmation")
+	positional := parseInterspersed(undoCmd, args)
+
+	if len(positional) > 1 {
+		fmt.Println("Usage: dcli undo [id] [-run] [-yes]")
+		undoCmd.Usage()
+		os.Exit(1)
+	}
+
+	j := client.Recorder.(*journal)
+	entries, err := readJournal(j.path)
+	if err != nil {
+		utils.ErrorLogger.Println("Failed to read the journal:", err)
+		os.Exit(1)
+	}
+	undone := undoneEntries(entries)
+
+	// Without an ID, undo the latest change that was not undone yet
+	var target *journalEntry
+	if len(positional) == 1 {
+		id, err := strconv.Atoi(strings.TrimPrefix(positional[0], "#"))
+		if err != nil {
+			utils.ErrorLogger.Printf("Invalid journal entry %q: expected the number shown by 'dcli history'", positional[0])
+			os.Exit(1)
+		}
+		for _, entry := range entries {
+			if entry.ID == id {
+				target = entry
+			}
+		}
+		switch {
+		case target == nil:
+			utils.ErrorLogger.Printf("No change #%d in %s", id, j.path)
+			os.Exit(1)
+		case target.Kind == "undo":
+			utils.ErrorLogger.Printf("#%d is an undo, which cannot be undone", id)
+			os.Exit(1)
+		}
+		if by, ok := undone[id]; ok {
+			utils.ErrorLogger.Printf("#%d was already undone by #%d", id, by)
+			os.Exit(1)
+		}
+	} else {
+		for i := len(entries) - 1; i >= 0 && target == nil; i-- {
+			if _, ok := undone[entries[i].ID]; !ok && entries[i].Kind != "undo" {
+				target = entries[i]
+			}
+		}
+		if target == nil {
+			utils.ErrorLogger.Println("Nothing to undo.")
+			os.Exit(1)
+		}
+	}
+
+	// Later changes are undone first, so that earlier ones restore the oldest state
+	selected := []*journalEntry{target}
+	if *run {
+		selected = nil
+		for i := len(entries) - 1; i >= 0; i-- {
+			entry := entries[i]
+			if _, ok := undone[entry.ID]; !ok && entry.Run == target.Run && entry.Kind != "undo" {
+				selected = append(selected, entry)
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Changes to undo:\n")
+	for _, entry := range selected {
+		fmt.Fprintf(os.Stderr, "  #%d  %s  %s %s %s  (%s)\n", entry.ID, entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Kind, entry.Type, entry.ResourceID, entry.describe())
+	}
+	if !*yes && !globalOptions.dryRun {
+		answer, err := newPrompter().line("Undo these changes? [y/N] ")
+		if err != nil || !(strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")) {
+			fmt.Fprintln(os.Stderr, "Nothing undone.")
+			os.Exit(1)
+		}
+	}
+
+	// The undo is journaled as a whole below rather than change by change
+	client.Recorder = nil
+	var undoneIDs []int
+	var lines, failures []string
+	for _, entry := range selected {
+		done, err := entry.undo(client)
+		if isDryRun(err) {
+			return
+		}
+		if done != "" {
+			lines = append(lines, fmt.Sprintf("Undid #%d: %s.", entry.ID, done))
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("#%d: %v", entry.ID, err))
+			// A re-created record must not be created again by a second undo
+			if done == "" {
+				continue
+			}
+		}
+		undoneIDs = append(undoneIDs, entry.ID)
+	}
+
+	if len(undoneIDs) > 0 {
+		if err := j.append(&journalEntry{Kind: "undo", Undoes: undoneIDs}); err != nil {
+			utils.ErrorLogger.Println("Failed to record the undo in the journal:", err)
+		}
+	}
+	if len(lines) > 0 {
+		render(&View{Message: strings.Join(lines, "\n"), Data: map[string]interface{}{"undone": undoneIDs}})
+	}
+	if len(failures) > 0 {
+		for _, failure := range failures {
+			utils.ErrorLogger.Println("Failed to undo", failure)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,171 @@
+// models/document.go
+
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Resolve parses the primary data of the document and links every
+// relationship of the primary and included resources to the included
+// resources it refers to. The linked resources are available through
+// Relationship.Resources. Identifiers without a matching included resource
+// are left unresolved.
+func (d *Document) Resolve() ([]*Resource, error) {
+	primary, err := primaryResources(d.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]*Resource, len(d.Included))
+	for i := range d.Included {
+		res := &d.Included[i]
+		index[res.Type+"/"+res.ID] = res
+	}
+
+	link := func(res *Resource) {
+		for name, rel := range res.Relationships {
+			rel.Resources = nil
+			for _, identifier := range rel.Identifiers() {
+				if related, ok := index[identifier.Type+"/"+identifier.ID]; ok {
+					rel.Resources = append(rel.Resources, related)
+				}
+			}
+			res.Relationships[name] = rel
+		}
+	}
+	for _, res := range primary {
+		link(res)
+	}
+	for i := range d.Included {
+		link(&d.Included[i])
+	}
+
+	return primary, nil
+}
+
+// primaryResources decodes the primary data of a document, which may hold a
+// single resource or a list of resources.
+func primaryResources(data interface{}) ([]*Resource, error) {
+	if data == nil {
+		return nil, nil
+	}
+	switch v := data.(type) {
+	case *Resource:
+		return []*Resource{v}, nil
+	case []*Resource:
+		return v, nil
+	}
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var resources []*Resource
+	if len(dataBytes) > 0 && dataBytes[0] == '[' {
+		err = json.Unmarshal(dataBytes, &resources)
+	} else {
+		var res Resource
+		err = json.Unmarshal(dataBytes, &res)
+		resources = []*Resource{&res}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse primary data: %w", err)
+	}
+	return resources, nil
+}
+
+// IsToMany reports whether the relationship linkage is a list of identifiers.
+func (r Relationship) IsToMany() bool {
+	switch r.Data.(type) {
+	case []interface{}, []ResourceIdentifier:
+		return true
+	}
+	return false
+}
+
+// Identifiers returns the resource identifiers of the relationship linkage,
+// whether it was built in code or decoded from JSON.
+func (r Relationship) Identifiers() []ResourceIdentifier {
+	switch v := r.Data.(type) {
+	case ResourceIdentifier:
+		return []ResourceIdentifier{v}
+	case *ResourceIdentifier:
+		if v == nil {
+			return nil
+		}
+		return []ResourceIdentifier{*v}
+	case []ResourceIdentifier:
+		return v
+	case map[string]interface{}:
+		if identifier, ok := identifierFromMap(v); ok {
+			return []ResourceIdentifier{identifier}
+		}
+	case []interface{}:
+		var identifiers []ResourceIdentifier
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				if identifier, ok := identifierFromMap(m); ok {
+					identifiers = append(identifiers, identifier)
+				}
+			}
+		}
+		return identifiers
+	}
+	return nil
+}
+
+func identifierFromMap(m map[string]interface{}) (ResourceIdentifier, bool) {
+	resourceType, _ := m["type"].(string)
+	id, _ := m["id"].(string)
+	if resourceType == "" || id == "" {
+		return ResourceIdentifier{}, false
+	}
+	meta, _ := m["meta"].(map[string]interface{})
+	return ResourceIdentifier{Type: resourceType, ID: id, Meta: meta}, true
+}
+
+// Embedded returns the resource as a plain object in which every resolved
+// relationship is nested under "related": a single object for to-one
+// relationships and a list for to-many ones. Resources already being
+// embedded further up are not repeated, which keeps cycles finite.
+func (r *Resource) Embedded() map[string]interface{} {
+	return r.embedded(map[string]bool{})
+}
+
+func (r *Resource) embedded(seen map[string]bool) map[string]interface{} {
+	key := r.Type + "/" + r.ID
+	seen[key] = true
+	defer delete(seen, key)
+
+	out := map[string]interface{}{
+		"type":       r.Type,
+		"id":         r.ID,
+		"attributes": r.Attributes,
+	}
+
+	related := make(map[string]interface{})
+	for name, rel := range r.Relationships {
+		if len(rel.Resources) == 0 {
+			continue
+		}
+		var nested []interface{}
+		for _, res := range rel.Resources {
+			if seen[res.Type+"/"+res.ID] {
+				nested = append(nested, map[string]interface{}{"type": res.Type, "id": res.ID})
+				continue
+			}
+			nested = append(nested, res.embedded(seen))
+		}
+		if rel.IsToMany() {
+			related[name] = nested
+		} else {
+			related[name] = nested[0]
+		}
+	}
+	if len(related) > 0 {
+		out["related"] = related
+	}
+	return out
+}
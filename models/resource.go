@@ -24,12 +24,28 @@ type Relationship struct {
 	Links *Links                 `json:"links,omitempty"`
 	Data  interface{}            `json:"data,omitempty"` // Can be ResourceIdentifier or []ResourceIdentifier
 	Meta  map[string]interface{} `json:"meta,omitempty"`
+
+	// Resources holds the included resources the linkage refers to, once
+	// linked by Document.Resolve.
+	Resources []*Resource `json:"-"`
 }
 
 // Links represents a links object.
 type Links struct {
 	Self    string `json:"self,omitempty"`
 	Related string `json:"related,omitempty"`
+	First   string `json:"first,omitempty"`
+	Last    string `json:"last,omitempty"`
+	Prev    string `json:"prev,omitempty"`
+	Next    string `json:"next,omitempty"`
+
+	// Pagination details reported by daptin on list responses.
+	CurrentPage int `json:"current_page,omitempty"`
+	From        int `json:"from,omitempty"`
+	To          int `json:"to,omitempty"`
+	LastPage    int `json:"last_page,omitempty"`
+	PerPage     int `json:"per_page,omitempty"`
+	Total       int `json:"total,omitempty"`
 }
 
 // Document represents a JSON:API document.
@@ -13,9 +13,32 @@ import (
 type Config struct {
 	BaseURL string `json:"base_url"`
 	APIKey  string `json:"api_key,omitempty"`
+	// Profiles are further servers or accounts, chosen with --profile
+	Profiles map[string]Profile `json:"profiles,omitempty"`
 	// Add more configuration fields as needed
 }
 
+// Profile is a named set of connection settings.
+type Profile struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key,omitempty"`
+}
+
+// DefaultProfile names the top-level settings of the configuration.
+const DefaultProfile = "default"
+
+// Profile returns the settings of a profile. An empty name, or "default"
+// when no profile has that name, selects the top-level settings.
+func (c *Config) Profile(name string) (Profile, error) {
+	if profile, ok := c.Profiles[name]; ok {
+		return profile, nil
+	}
+	if name == "" || name == DefaultProfile {
+		return Profile{BaseURL: c.BaseURL, APIKey: c.APIKey}, nil
+	}
+	return Profile{}, fmt.Errorf("no profile named %q in the configuration", name)
+}
+
 func LoadConfig(configPath string) (*Config, error) {
 	if configPath == "" {
 		configPath = DefaultConfigPath()
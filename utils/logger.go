@@ -14,11 +14,13 @@ var (
 	DebugLogger *log.Logger
 )
 
+// InitLogger sets up the loggers. Everything goes to stderr so that command
+// output on stdout can be piped into other tools.
 func InitLogger(debug bool) {
-	InfoLogger = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime)
+	InfoLogger = log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime)
 	ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 	if debug {
-		DebugLogger = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+		DebugLogger = log.New(os.Stderr, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
 	} else {
 		DebugLogger = log.New(io.Discard, "", 0)
 	}